@@ -25,9 +25,6 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
 )
 
@@ -37,11 +34,7 @@ var apiCmd = &cobra.Command{
 	Short: "parent command for api related functions",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Currently api only has one sub command
-		fmt.Fprint(os.Stderr, "The api command cannot be run directly. Please run: kusk api generate\n")
-
-		// In future, remove this when new sub commands are added and simply call cmd.Help()
-		generateCmd.Help()
+		cmd.Help()
 	},
 }
 
@@ -0,0 +1,138 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubeshop/kusk/k8s"
+)
+
+var apiApplyTimeout time.Duration
+
+// apiApplyCmd represents the api apply command
+var apiApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Generate a Kusk Gateway API resource from your OpenAPI spec file and apply it to your cluster",
+	Long: `
+	Apply generates the API resource exactly like kusk api generate does, taking the same flags,
+	and creates or updates it in the cluster of the current context of your kubeconfig with a server-side apply.
+
+	When the API is created, kusk then waits until the kusk gateway controller has picked up the API. The controller
+	doesn't report when it reconciles updates, so kusk doesn't wait for updates of an API the controller already picked up:
+	check the logs of kusk gateway in the kusk-system namespace to follow them. When the admission webhooks of
+	kusk gateway reject the API, the reasons they gave are printed and kusk exits with a non-zero code.
+
+	Sample usage
+
+	kusk api apply \
+		-i spec.yaml \
+		--name httpbin-api \
+		--upstream.service httpbin \
+		--upstream.port 8080 \
+		--envoyfleet.name kusk-gateway-envoy-fleet
+
+	Specify the kubeconfig and how long to wait for the controller
+	kusk api apply \
+		-i spec.yaml \
+		--envoyfleet.name kusk-gateway-envoy-fleet \
+		--kubeconfig /path/to/kube/config \
+		--timeout 2m
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := generateAPIResource()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		client, err := newDynamicClient()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		api, err := k8s.ApplyAPI(ctx, client, []byte(manifest))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("api.gateway.kusk.io/%s applied in namespace %s\n", api.GetName(), api.GetNamespace())
+
+		// the finalizer of the controller is only added once, so updates can't be waited for
+		if k8s.PickedUp(api) {
+			fmt.Printf("api.gateway.kusk.io/%s was already picked up by kusk gateway, which doesn't report when it reconciles updates: check the logs of kusk gateway in the kusk-system namespace\n", api.GetName())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, apiApplyTimeout)
+		defer cancel()
+
+		if err := k8s.WaitForAPI(ctx, client, api.GetNamespace(), api.GetName()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("api.gateway.kusk.io/%s accepted by kusk gateway\n", api.GetName())
+	},
+}
+
+// newDynamicClient returns a client for the cluster of the current context of the kubeconfig
+func newDynamicClient() (dynamic.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamic.NewForConfig(config)
+}
+
+// addKubeConfigFlag adds the --kubeconfig flag shared with kusk dashboard to cmd
+func addKubeConfigFlag(cmd *cobra.Command) {
+	kubeConfigDefault := ""
+	if home := homeDir(); home != "" {
+		kubeConfigDefault = filepath.Join(home, ".kube", "config")
+	}
+
+	cmd.Flags().StringVarP(&kubeConfig, "kubeconfig", "", kubeConfigDefault, "absolute path to kube config")
+}
+
+func init() {
+	apiCmd.AddCommand(apiApplyCmd)
+	addGenerateFlags(apiApplyCmd)
+	apiApplyCmd.MarkFlagRequired("envoyfleet.name")
+	addKubeConfigFlag(apiApplyCmd)
+
+	apiApplyCmd.Flags().DurationVar(&apiApplyTimeout, "timeout", time.Minute, "how long to wait for the kusk gateway controller to pick up a new API")
+}
@@ -0,0 +1,145 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/spf13/cobra"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/apidiff"
+)
+
+// exit codes of kusk api breaking-changes
+const (
+	breakingChangesExitFound = 1
+	breakingChangesExitError = 2
+)
+
+var (
+	breakingChangesBase     string
+	breakingChangesRevision string
+	breakingChangesOutput   string
+)
+
+// apiBreakingChangesCmd represents the api breaking-changes command
+var apiBreakingChangesCmd = &cobra.Command{
+	Use:   "breaking-changes",
+	Short: "Report the changes of an OpenAPI spec that break the clients of its previous version",
+	Long: `
+	Breaking-changes compares two versions of an OpenAPI spec and reports the changes that break clients of the base version:
+	removed operations, new required parameters and request body fields, narrowed types, e.g. a type changed, enum values
+	removed or a maximum lowered, removed response fields, responses that may now be null, return new enum values or variants
+	or leave out fields that were required, and status codes no longer returned. Schemas composed with allOf, oneOf and anyOf
+	are compared too, and paths are matched regardless of the names of their path parameters.
+
+	Both specs are parsed like kusk api generate does, from a file or a URL. The report is printed as text or, with -o json, as JSON.
+
+	Breaking-changes exits with 0 when no breaking change was found, 1 when some were found and 2 when the comparison failed, so it can gate CI pipelines.
+
+	Sample usage
+
+	kusk api breaking-changes --base main/spec.yaml --revision spec.yaml
+
+	kusk api breaking-changes --base https://example.com/spec.yaml --revision spec.yaml -o json
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := apiBreakingChanges()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(breakingChangesExitError)
+		}
+
+		if err := printBreakingChanges(changes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(breakingChangesExitError)
+		}
+
+		if len(changes) > 0 {
+			os.Exit(breakingChangesExitFound)
+		}
+	},
+}
+
+// apiBreakingChanges parses both specs and compares them
+func apiBreakingChanges() ([]apidiff.BreakingChange, error) {
+	switch breakingChangesOutput {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("invalid output %q, expected text or json", breakingChangesOutput)
+	}
+
+	base, err := spec.NewParser(openapi3.NewLoader()).Parse(breakingChangesBase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse base spec %s: %w", breakingChangesBase, err)
+	}
+
+	revision, err := spec.NewParser(openapi3.NewLoader()).Parse(breakingChangesRevision)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse revision spec %s: %w", breakingChangesRevision, err)
+	}
+
+	return apidiff.Breaking(base, revision), nil
+}
+
+func printBreakingChanges(changes []apidiff.BreakingChange) error {
+	if breakingChangesOutput == "json" {
+		// an empty list rather than null, so it can be counted without checking for null
+		if changes == nil {
+			changes = []apidiff.BreakingChange{}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("no breaking changes from %s to %s\n", breakingChangesBase, breakingChangesRevision)
+		return nil
+	}
+
+	fmt.Printf("%d breaking changes from %s to %s:\n", len(changes), breakingChangesBase, breakingChangesRevision)
+	for _, change := range changes {
+		fmt.Println("  " + change.String())
+	}
+
+	return nil
+}
+
+func init() {
+	apiCmd.AddCommand(apiBreakingChangesCmd)
+
+	apiBreakingChangesCmd.Flags().StringVar(&breakingChangesBase, "base", "", "file path or URL to the previous version of the OpenAPI spec")
+	apiBreakingChangesCmd.MarkFlagRequired("base")
+	apiBreakingChangesCmd.Flags().StringVar(&breakingChangesRevision, "revision", "", "file path or URL to the new version of the OpenAPI spec")
+	apiBreakingChangesCmd.MarkFlagRequired("revision")
+	apiBreakingChangesCmd.Flags().StringVarP(&breakingChangesOutput, "output", "o", "text", "output format, text or json")
+}
@@ -0,0 +1,151 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/apidiff"
+	"github.com/kubeshop/kusk/k8s"
+)
+
+// exit codes of kusk api diff, following diff(1)
+const (
+	apiDiffExitChanged = 1
+	apiDiffExitError   = 2
+)
+
+// apiDiffCmd represents the api diff command
+var apiDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how the API resource generated from your OpenAPI spec differs from the one deployed in your cluster",
+	Long: `
+	Diff generates the API resource exactly like kusk api generate does, taking the same flags, fetches the API
+	resource with the same name deployed in the cluster of the current context of your kubeconfig, and compares their
+	OpenAPI specs: the paths and operations added and removed, the upstreams changed and the other x-kusk options changed,
+	reported where they're set, e.g. paths./pets.get.x-kusk.qos.retries. The envoyfleet is compared when --envoyfleet.name is set.
+
+	Diff exits with 0 when nothing changed, 1 when something changed and 2 when the comparison failed, so it can gate CI pipelines.
+
+	Sample usage
+
+	kusk api diff -i spec.yaml --name my-api -n my-namespace
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := apiDiff(cmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(apiDiffExitError)
+		}
+
+		if len(changes) == 0 {
+			fmt.Printf("api.gateway.kusk.io/%s is up to date\n", name)
+			return
+		}
+
+		fmt.Printf("api.gateway.kusk.io/%s in namespace %s differs from %s:\n", name, namespace, apiSpecPath)
+		for _, change := range changes {
+			fmt.Println("  " + change.String())
+		}
+		os.Exit(apiDiffExitChanged)
+	},
+}
+
+// apiDiff compares the API resource deployed with the one generated from the spec and flags
+func apiDiff(cmd *cobra.Command) ([]apidiff.Change, error) {
+	generated, err := generateAPISpec()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newDynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := k8s.GetAPI(context.Background(), client, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	liveSpec, err := parseLiveAPISpec(live)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := spec.NewParser(openapi3.NewLoader()).ParseFromReader(strings.NewReader(generated))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse generated spec: %w", err)
+	}
+
+	changes, err := apidiff.Compare(liveSpec, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Flags().Changed("envoyfleet.name") {
+		fleet, _, _ := unstructured.NestedStringMap(live.Object, "spec", "fleet")
+		if fleet["name"] != envoyFleetName || fleet["namespace"] != envoyFleetNamespace {
+			changes = append([]apidiff.Change{{
+				Kind:     apidiff.FleetChanged,
+				Location: "spec.fleet",
+				From:     fleet["namespace"] + "/" + fleet["name"],
+				To:       envoyFleetNamespace + "/" + envoyFleetName,
+			}}, changes...)
+		}
+	}
+
+	return changes, nil
+}
+
+// parseLiveAPISpec parses the OpenAPI spec embedded in spec.spec of the API resource
+func parseLiveAPISpec(api *unstructured.Unstructured) (*openapi3.T, error) {
+	embedded, ok, err := unstructured.NestedString(api.Object, "spec", "spec")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("API %s/%s has no spec.spec", api.GetNamespace(), api.GetName())
+	}
+
+	apiSpec, err := spec.NewParser(openapi3.NewLoader()).ParseFromReader(strings.NewReader(embedded))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse spec.spec of API %s/%s: %w", api.GetNamespace(), api.GetName(), err)
+	}
+
+	return apiSpec, nil
+}
+
+func init() {
+	apiCmd.AddCommand(apiDiffCmd)
+	addGenerateFlags(apiDiffCmd)
+	addKubeConfigFlag(apiDiffCmd)
+}
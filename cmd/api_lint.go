@@ -0,0 +1,246 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/kubeshop/kusk-gateway/pkg/build"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/lint"
+	"github.com/kubeshop/kusk/k8s"
+)
+
+// exit codes of kusk api lint
+const (
+	apiLintExitErrors = 1
+	apiLintExitFailed = 2
+)
+
+// apiLintServiceTimeout bounds the lookup of each upstream service in the cluster
+const apiLintServiceTimeout = 10 * time.Second
+
+var (
+	apiLintOutput           string
+	apiLintResolveUpstreams bool
+)
+
+// apiLintCmd represents the api lint command
+var apiLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check your OpenAPI spec for mistakes that keep kusk gateway from serving it or kusk mock from mocking it",
+	Long: `
+	Lint runs rules over your OpenAPI spec and reports what they find with the severity of the rule:
+
+	x-kusk             (error)   x-kusk options at the root, path and operation level are valid once merged over their parents, as kusk gateway reads them
+	upstream           (error)   every operation routes to an upstream, a redirect or a mock, and, with --resolve-upstreams, upstream services
+	                             exist in the cluster of the current context of your kubeconfig and expose the port
+	operation-id       (warning) every operation has a unique operationId
+	response-examples  (warning) every response with content has an example, which mocking serves instead of generated data
+
+	The severity of each rule can be changed, or the rule turned off, under lint.rules in $HOME/.kusk.yaml or in the .kusk.yaml
+	of the project of the spec, next to the spec or at the root of its git repository, which overrides the global rules, e.g.
+
+	lint:
+	  rules:
+	    operation-id: off
+	    response-examples: info
+
+	Findings are printed as text, JSON or, with -o sarif, as a SARIF log that code scanning tools use to annotate pull requests.
+
+	Lint exits with 0 when there are no errors, 1 when there are errors and 2 when the spec couldn't be linted, so it can gate CI pipelines.
+
+	Sample usage
+
+	kusk api lint -i spec.yaml
+
+	kusk api lint -i spec.yaml --resolve-upstreams
+
+	kusk api lint -i spec.yaml -o sarif > kusk.sarif
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		findings, err := apiLint()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(apiLintExitFailed)
+		}
+
+		if err := printLintFindings(findings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(apiLintExitFailed)
+		}
+
+		if lint.HasErrors(findings) {
+			os.Exit(apiLintExitErrors)
+		}
+	},
+}
+
+// apiLint parses the spec and lints it with the rules configured in the kusk config
+func apiLint() ([]lint.Finding, error) {
+	switch apiLintOutput {
+	case "text", "json", "sarif":
+	default:
+		return nil, fmt.Errorf("invalid output %q, expected text, json or sarif", apiLintOutput)
+	}
+
+	config, err := lintConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	apiSpec, err := spec.NewParser(openapi3.NewLoader()).Parse(apiSpecPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse spec %s: %w", apiSpecPath, err)
+	}
+
+	lintSpec := &lint.Spec{T: apiSpec}
+	if apiLintResolveUpstreams {
+		client, err := newDynamicClient()
+		if err != nil {
+			return nil, err
+		}
+
+		lintSpec.Services = func(namespace, name string) ([]int64, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), apiLintServiceTimeout)
+			defer cancel()
+
+			return k8s.GetServicePorts(ctx, client, namespace, name)
+		}
+	}
+
+	findings, err := lint.Lint(lintSpec, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// specs loaded from URLs are reported without lines
+	if !isURL(apiSpecPath) {
+		if source, err := os.ReadFile(apiSpecPath); err == nil {
+			lint.SetLines(findings, source)
+		}
+	}
+
+	return findings, nil
+}
+
+// lintConfig reads lint.rules of the kusk config, overridden by the ones of the project config of the spec.
+// YAML reads off as false, so rules can be turned off with false as well, and turned on with their default severity with true.
+func lintConfig() (lint.Config, error) {
+	config := lint.Config{}
+	if err := readLintRules(config, viper.GetStringMap("lint.rules"), viper.ConfigFileUsed()); err != nil {
+		return nil, err
+	}
+
+	file, err := findProjectFile(apiSpecPath, projectConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		project, err := readProjectConfig(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := readLintRules(config, project.GetStringMap("lint.rules"), file); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, config.Validate()
+}
+
+// readLintRules reads the severities of the rules read from file into config
+func readLintRules(config lint.Config, rules map[string]interface{}, file string) error {
+	for id, value := range rules {
+		switch value := value.(type) {
+		case bool:
+			config[id] = lint.Off
+			if value {
+				// turned back on with its default severity, over an earlier config turning it off
+				delete(config, id)
+			}
+		case string:
+			config[id] = lint.Severity(value)
+		default:
+			return fmt.Errorf("invalid severity %v for lint rule %s in %s, expected error, warning, info or off", value, id, file)
+		}
+	}
+
+	return nil
+}
+
+func printLintFindings(findings []lint.Finding) error {
+	switch apiLintOutput {
+	case "json":
+		// an empty list rather than null, so it can be counted without checking for null
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(findings)
+	case "sarif":
+		return lint.WriteSARIF(os.Stdout, findings, apiSpecPath, build.Version)
+	}
+
+	if len(findings) == 0 {
+		fmt.Printf("%s: no findings\n", apiSpecPath)
+		return nil
+	}
+
+	counts := map[lint.Severity]int{}
+	for _, finding := range findings {
+		position := apiSpecPath
+		if finding.Line > 0 {
+			position = fmt.Sprintf("%s:%d", apiSpecPath, finding.Line)
+		}
+		fmt.Printf("%s: %s: %s\n", position, finding.Severity, finding)
+		counts[finding.Severity]++
+	}
+	fmt.Printf("\n%d errors, %d warnings, %d infos\n", counts[lint.Error], counts[lint.Warning], counts[lint.Info])
+
+	return nil
+}
+
+func init() {
+	apiCmd.AddCommand(apiLintCmd)
+
+	apiLintCmd.Flags().StringVarP(&apiSpecPath, "in", "i", "", "file path or URL to the OpenAPI spec to lint, e.g. --in apispec.yaml")
+	apiLintCmd.MarkFlagRequired("in")
+	apiLintCmd.Flags().StringVarP(&apiLintOutput, "output", "o", "text", "output format, text, json or sarif")
+	apiLintCmd.Flags().BoolVar(&apiLintResolveUpstreams, "resolve-upstreams", false, "check that upstream services exist in the cluster and expose the port")
+	addKubeConfigFlag(apiLintCmd)
+}
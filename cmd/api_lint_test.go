@@ -0,0 +1,91 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk/internal/lint"
+)
+
+func TestLintConfig(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(viper.Reset)
+
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+lint:
+  rules:
+    operation-id: off
+    response-examples: info
+    upstream: true
+`)))
+
+	config, err := lintConfig()
+	require.NoError(t, err)
+	assert.Equal(lint.Config{"operation-id": lint.Off, "response-examples": lint.Info}, config)
+
+	viper.Set("lint.rules", map[string]interface{}{"x-kusk": "fatal"})
+	_, err = lintConfig()
+	assert.EqualError(err, `invalid severity "fatal" for lint rule x-kusk, expected error, warning, info or off`)
+}
+
+func TestLintConfigProject(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(viper.Reset)
+	specPath := apiSpecPath
+	t.Cleanup(func() { apiSpecPath = specPath })
+
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+lint:
+  rules:
+    operation-id: info
+    response-examples: off
+`)))
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".kusk.yaml"), []byte(`
+lint:
+  rules:
+    operation-id: off
+    response-examples: true
+`), 0644))
+
+	// the project config at the root of the git repository of the spec overrides the global rules
+	apiSpecPath = filepath.Join(root, "api", "spec.yaml")
+	config, err := lintConfig()
+	require.NoError(t, err)
+	assert.Equal(lint.Config{"operation-id": lint.Off}, config)
+}
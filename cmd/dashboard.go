@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
@@ -30,12 +29,7 @@ var (
 func init() {
 	rootCmd.AddCommand(dashboardCmd)
 
-	kubeConfigDefault := ""
-	if home := homeDir(); home != "" {
-		kubeConfigDefault = filepath.Join(home, ".kube", "config")
-	}
-
-	dashboardCmd.Flags().StringVarP(&kubeConfig, "kubeconfig", "", kubeConfigDefault, "absolute path to kube config")
+	addKubeConfigFlag(dashboardCmd)
 	dashboardCmd.Flags().StringVarP(&dashboardEnvoyFleetNamespace, "envoyfleet.namespace", "", "kusk-system", "kusk gateway dashboard envoy fleet namespace")
 	dashboardCmd.Flags().StringVarP(&dashboardEnvoyFleetName, "envoyfleet.name", "", "kusk-gateway-private-envoy-fleet", "kusk gateway dashboard envoy fleet service name")
 	dashboardCmd.Flags().IntVarP(&dashboardEnvoyFleetExternalPort, "external-port", "", 8080, "external port to access dashboard at")
@@ -25,6 +25,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -36,6 +37,7 @@ import (
 
 	"github.com/kubeshop/kusk-gateway/pkg/options"
 	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/extension"
 	"github.com/kubeshop/kusk/templates"
 )
 
@@ -45,7 +47,6 @@ var (
 
 	name      string
 	namespace string
-	apiSpec   string
 
 	serviceName      string
 	serviceNamespace string
@@ -119,77 +120,90 @@ var generateCmd = &cobra.Command{
 	This will fetch the OpenAPI document from the provided URL and generate a Kusk Gateway API resource
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
-		parsedApiSpec, err := spec.NewParser(openapi3.NewLoader()).Parse(apiSpecPath)
+		manifest, err := generateAPIResource()
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
-		if _, ok := parsedApiSpec.ExtensionProps.Extensions["x-kusk"]; !ok {
-			parsedApiSpec.ExtensionProps.Extensions["x-kusk"] = options.Options{}
-		}
+		fmt.Fprint(os.Stdout, manifest)
+	},
+}
 
-		// if name flag is not defined, use the swagger doc title which is guarunteed to be there
-		if name == "" {
-			// kubernetes manifests cannot have . in the name so replace them
-			name = strings.ReplaceAll(parsedApiSpec.Info.Title, ".", "-")
-		}
+// generateAPIResource returns the manifest of the API resource generated from the spec and flags
+func generateAPIResource() (string, error) {
+	apiSpec, err := generateAPISpec()
+	if err != nil {
+		return "", err
+	}
 
-		// override top level upstream service if undefined.
-		if serviceName != "" && serviceNamespace != "" && servicePort != 0 {
-			xKusk := parsedApiSpec.ExtensionProps.Extensions["x-kusk"].(options.Options)
-			xKusk.Upstream = &options.UpstreamOptions{
-				Service: &options.UpstreamService{
-					Name:      serviceName,
-					Namespace: serviceNamespace,
-					Port:      servicePort,
-				},
-			}
-
-			parsedApiSpec.ExtensionProps.Extensions["x-kusk"] = xKusk
-		}
+	var manifest strings.Builder
+	if err := apiTemplate.Execute(&manifest, templates.APITemplateArgs{
+		Name:                name,
+		Namespace:           namespace,
+		EnvoyfleetName:      envoyFleetName,
+		EnvoyfleetNamespace: envoyFleetNamespace,
+		Spec:                strings.Split(apiSpec, "\n"),
+	}); err != nil {
+		return "", err
+	}
 
-		if err := validateExtensionOptions(parsedApiSpec.ExtensionProps.Extensions["x-kusk"]); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+	return manifest.String(), nil
+}
 
-		if apiSpec, err = getAPISpecString(parsedApiSpec); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+// generateAPISpec returns the spec embedded in the API resource, with the upstream set by the flags.
+// The name of the API defaults to the title of the spec.
+func generateAPISpec() (string, error) {
+	parsedApiSpec, err := spec.NewParser(openapi3.NewLoader()).Parse(apiSpecPath)
+	if err != nil {
+		return "", err
+	}
 
-		if err := apiTemplate.Execute(os.Stdout, templates.APITemplateArgs{
-			Name:                name,
-			Namespace:           namespace,
-			EnvoyfleetName:      envoyFleetName,
-			EnvoyfleetNamespace: envoyFleetNamespace,
-			Spec:                strings.Split(apiSpec, "\n"),
-		}); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+	if _, ok := parsedApiSpec.ExtensionProps.Extensions["x-kusk"]; !ok {
+		parsedApiSpec.ExtensionProps.Extensions["x-kusk"] = options.Options{}
+	}
+
+	// if name flag is not defined, use the swagger doc title which is guarunteed to be there
+	if name == "" {
+		// kubernetes manifests cannot have . in the name so replace them
+		name = strings.ReplaceAll(parsedApiSpec.Info.Title, ".", "-")
+	}
+
+	// override top level upstream service if undefined.
+	if serviceName != "" && serviceNamespace != "" && servicePort != 0 {
+		xKusk := parsedApiSpec.ExtensionProps.Extensions["x-kusk"].(options.Options)
+		xKusk.Upstream = &options.UpstreamOptions{
+			Service: &options.UpstreamService{
+				Name:      serviceName,
+				Namespace: serviceNamespace,
+				Port:      servicePort,
+			},
 		}
-	},
-}
 
-func validateExtensionOptions(extension interface{}) error {
-	b, err := yaml.Marshal(extension)
-	if err != nil {
-		return err
+		parsedApiSpec.ExtensionProps.Extensions["x-kusk"] = xKusk
 	}
 
-	var o options.Options
-	if err := yaml.Unmarshal(b, &o); err != nil {
-		return err
+	if err := validateExtensionOptions(parsedApiSpec); err != nil {
+		return "", err
 	}
 
-	o.FillDefaults()
+	return getAPISpecString(parsedApiSpec)
+}
+
+// validateExtensionOptions validates the x-kusk options in effect at the root, every path and every operation
+// of the spec as kusk gateway merges them, and reports all the options it would reject where they're declared
+func validateExtensionOptions(apiSpec *openapi3.T) error {
+	errs := extension.Validate(apiSpec)
+	if len(errs) == 0 {
+		return nil
+	}
 
-	if err := o.Validate(); err != nil {
-		return err
+	message := "invalid x-kusk options:"
+	for _, err := range errs {
+		message += "\n  " + err.Error()
 	}
 
-	return nil
+	return errors.New(message)
 }
 
 func getAPISpecString(apiSpec *openapi3.T) (string, error) {
@@ -208,8 +222,15 @@ func getAPISpecString(apiSpec *openapi3.T) (string, error) {
 
 func init() {
 	apiCmd.AddCommand(generateCmd)
+	addGenerateFlags(generateCmd)
+	generateCmd.MarkFlagRequired("envoyfleet.name")
+
+	apiTemplate = template.Must(template.New("api").Parse(templates.APITemplate))
+}
 
-	generateCmd.Flags().StringVarP(
+// addGenerateFlags adds the flags the API resource is generated from to cmd
+func addGenerateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
 		&name,
 		"name",
 		"",
@@ -217,7 +238,7 @@ func init() {
 		"the name to give the API resource e.g. --name my-api",
 	)
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&namespace,
 		"namespace",
 		"n",
@@ -225,16 +246,16 @@ func init() {
 		"the namespace of the API resource e.g. --namespace my-namespace, -n my-namespace",
 	)
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&apiSpecPath,
 		"in",
 		"i",
 		"",
 		"file path or URL to OpenAPI spec file to generate mappings from. e.g. --in apispec.yaml",
 	)
-	generateCmd.MarkFlagRequired("in")
+	cmd.MarkFlagRequired("in")
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&serviceName,
 		"upstream.service",
 		"",
@@ -242,7 +263,7 @@ func init() {
 		"name of upstream service",
 	)
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&serviceNamespace,
 		"upstream.namespace",
 		"",
@@ -250,7 +271,7 @@ func init() {
 		"namespace of upstream service",
 	)
 
-	generateCmd.Flags().Uint32VarP(
+	cmd.Flags().Uint32VarP(
 		&servicePort,
 		"upstream.port",
 		"",
@@ -258,22 +279,19 @@ func init() {
 		"port of upstream service",
 	)
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&envoyFleetName,
 		"envoyfleet.name",
 		"",
 		"",
 		"name of envoyfleet to use for this API",
 	)
-	generateCmd.MarkFlagRequired("envoyfleet.name")
 
-	generateCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&envoyFleetNamespace,
 		"envoyfleet.namespace",
 		"",
 		"kusk-system",
 		"namespace of envoyfleet to use for this API. Default: kusk-system",
 	)
-
-	apiTemplate = template.Must(template.New("api").Parse(templates.APITemplate))
 }
@@ -0,0 +1,119 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invalidExtensionSpec = `openapi: 3.0.0
+info:
+  title: pets
+  version: 1.0.0
+x-kusk:
+  qos:
+    retries: 3
+paths:
+  /pets:
+    x-kusk:
+      upstream:
+        service:
+          name: pets
+          port: 70000
+    get:
+      x-kusk:
+        qos:
+          retries: three
+      responses:
+        '200':
+          description: pets
+    post:
+      x-kusk:
+        cors:
+          origins:
+            - "*"
+      responses:
+        '201':
+          description: created
+`
+
+func TestGenerateAPISpecValidatesExtensions(t *testing.T) {
+	assert := assert.New(t)
+
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(invalidExtensionSpec), 0644))
+
+	previous := apiSpecPath
+	apiSpecPath = specPath
+	t.Cleanup(func() { apiSpecPath = previous })
+
+	// the upstream port inherited by POST /pets is only reported on /pets
+	_, err := generateAPISpec()
+	assert.EqualError(err, `invalid x-kusk options:
+  paths./pets.get.x-kusk.qos.retries: invalid string, expected uint32
+  paths./pets.x-kusk.upstream.service.port: must be no greater than 65356`)
+}
+
+const mockingWithValidationSpec = `openapi: 3.0.0
+info:
+  title: pets
+  version: 1.0.0
+x-kusk:
+  upstream:
+    service:
+      name: pets
+  validation:
+    request:
+      enabled: true
+paths:
+  /pets:
+    get:
+      x-kusk:
+        mocking: {}
+      responses:
+        '200':
+          description: pets
+`
+
+func TestGenerateAPISpecMockingWithValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(mockingWithValidationSpec), 0644))
+
+	previous := apiSpecPath
+	apiSpecPath = specPath
+	t.Cleanup(func() { apiSpecPath = previous })
+
+	// kusk gateway dereferences mocking.enabled when validation is enabled as well
+	_, err := generateAPISpec()
+	assert.EqualError(err, `invalid x-kusk options:
+  paths./pets.get.x-kusk.mocking: validation.request.enabled and mocking.enabled must both be set when validation and mocking are combined`)
+}
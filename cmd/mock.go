@@ -26,9 +26,11 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -37,13 +39,16 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/kubeshop/kusk/internal/config"
 	"github.com/kubeshop/kusk/internal/mocking"
 	fileWatcher "github.com/kubeshop/kusk/internal/mocking/filewatcher"
+	urlPoller "github.com/kubeshop/kusk/internal/mocking/urlpoller"
 	"github.com/kubeshop/testkube/pkg/ui"
 	"github.com/spf13/cobra"
 
@@ -51,7 +56,41 @@ import (
 	mockingServer "github.com/kubeshop/kusk/internal/mocking/server"
 )
 
-var mockServerPort uint32
+const (
+	mockBackendNative = "native"
+	mockBackendDocker = "docker"
+)
+
+const (
+	mockLogFormatText = "text"
+	mockLogFormatJSON = "json"
+)
+
+var (
+	mockServerPort       uint32
+	mockInputs           []string
+	mockBackend          string
+	mockValidateRequests bool
+	mockStateful         bool
+	mockScenario         string
+	mockRecord           string
+	mockUpstream         string
+	mockReplay           string
+	mockAdmin            bool
+	mockAdminJournalSize int
+	mockLogFormat        string
+	mockLogFile          string
+	mockSeed             int64
+	mockPollInterval     time.Duration
+	mockTLSCert          string
+	mockTLSKey           string
+	mockTLSSelfSigned    bool
+	mockCORS             string
+	mockEnforceSecurity  bool
+	mockAddress          string
+	// mockSeedSet tells a seed of 0 apart from no seed
+	mockSeedSet bool
+)
 
 // mockCmd represents the mock command
 var mockCmd = &cobra.Command{
@@ -90,6 +129,19 @@ The mock server will return a response like the following that matches the schem
  "url": "http://langosh.name/andreanne.parker"
 }
 
+The x-kusk extension is honoured the same way kusk gateway does: disabled paths and operations aren't served,
+paths are exposed under their path prefix and when mocking is configured only the operations with mocking enabled are mocked.
+Preflight requests are answered and CORS headers added according to the cors options, allowing any origin when they're not set.
+
+Specs on the file system are reloaded when they or the files they reference change, specs from a URL when --poll-interval
+is set and a new version is fetched. The changed spec is validated first: an invalid spec is reported and the previous
+version keeps being served until it's fixed, a valid one is swapped in without refusing connections in between.
+
+Responses are generated according to the mocking config in $HOME/.kusk/openapi-mock.yaml, overridden by the mock section
+of $HOME/.kusk.yaml, then by the mock section of the project config in .kusk.yaml next to the spec or at the root of its git repository,
+and then by flags such as --use-examples, --null-probability or --max-items. The config in effect is validated and printed
+before serving, and config files written by older versions of kusk are migrated in place.
+
 Example with example responses:
 
 application/xml:
@@ -113,8 +165,61 @@ $ kusk mock -i path-to-openapi-file.yaml
 
 To mock an api from a url
 $ kusk mock -i https://url.to.api.com
+
+To mock an api from a url, reloading it when it changes
+$ kusk mock -i https://url.to.api.com --poll-interval 30s
+
+To reject requests that don't match the spec with a 400 Bad Request
+$ kusk mock -i path-to-openapi-file.yaml --validate-requests
+
+To serve back the resources POSTed to collections such as /todos from item paths such as /todos/{id}
+$ kusk mock -i path-to-openapi-file.yaml --stateful
+
+To only serve generated responses, with at most 2 items in arrays and no null values
+$ kusk mock -i path-to-openapi-file.yaml --use-examples no --max-items 2 --null-probability 0
+
+To serve the same generated response for the same operation and parameters across requests and restarts
+$ kusk mock -i path-to-openapi-file.yaml --seed 42
+
+To reject requests missing the credentials required by the securitySchemes of the spec with a 401 Unauthorized
+$ kusk mock -i path-to-openapi-file.yaml --enforce-security
+
+To play back scripted responses, latencies and connection resets per operation from a scenario file
+$ kusk mock -i path-to-openapi-file.yaml --scenario scenario.yaml
+
+To proxy requests to a real service and record its responses as fixtures in the fixtures directory
+$ kusk mock -i path-to-openapi-file.yaml --record --upstream http://localhost:9000
+
+To serve the recorded fixtures, generating responses for requests that weren't recorded
+$ kusk mock -i path-to-openapi-file.yaml --replay fixtures/
+
+To inspect the requests served, reset the mock or switch the example served for an operation from tests
+$ kusk mock -i path-to-openapi-file.yaml --admin
+$ curl http://localhost:8080/__kusk/requests?operation=getTodo
+$ curl -X PUT http://localhost:8080/__kusk/examples/getTodo -d '{"example": "notFound"}'
+$ curl -X POST http://localhost:8080/__kusk/reset
+
+To write the access log as JSON lines to a file for other tools to ingest
+$ kusk mock -i path-to-openapi-file.yaml --log-format json --log-file access.log
+
+To serve over HTTPS with a self-signed certificate for localhost, or with your own certificate
+$ kusk mock -i path-to-openapi-file.yaml --tls-self-signed
+$ kusk mock -i path-to-openapi-file.yaml --tls-cert localhost.pem --tls-key localhost-key.pem
+
+To mock several apis on the same port, routing requests by path prefix
+$ kusk mock -i users.yaml:/users -i orders.yaml:/orders
+
+To mock an api with the openapi-mock container instead of the built in mock server
+$ kusk mock -i path-to-openapi-file.yaml --backend docker
 `,
+	// the mock commands read the mock section of the project config of the specs along with the kusk config
+	PersistentPreRunE: loadMockConfigSources,
 	Run: func(cmd *cobra.Command, args []string) {
+		// keep stdout a stream of json access log lines that can be parsed line by line
+		if mockLogFormat == mockLogFormatJSON {
+			ui.Writer = os.Stderr
+		}
+
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			ui.Fail(fmt.Errorf("unable to fetch user's home directory: %w", err))
@@ -124,50 +229,73 @@ $ kusk mock -i https://url.to.api.com
 			ui.Fail(err)
 		}
 
-		mockingConfigFilePath := path.Join(homeDir, ".kusk", "openapi-mock.yaml")
-		if err := writeMockingConfigIfNotExists(mockingConfigFilePath); err != nil {
+		mockingConfigFile := path.Join(homeDir, ".kusk", "openapi-mock.yaml")
+		mockingConfig, err := loadMockingConfig(cmd, mockingConfigFile)
+		if err != nil {
 			ui.Fail(err)
 		}
+		printMockingConfig(mockingConfig, mockingConfigFile)
 
-		spec, err := spec.NewParser(openapi3.NewLoader()).Parse(apiSpecPath)
+		writeLog, closeLog, err := newAccessLogWriter(mockLogFormat, mockLogFile)
 		if err != nil {
-			ui.Fail(fmt.Errorf("error when parsing openapi spec: %w", err))
+			ui.Fail(err)
 		}
+		defer closeLog()
 
-		if err := spec.Validate(context.Background()); err != nil {
-			ui.Fail(fmt.Errorf("openapi spec failed validation: %w", err))
+		inputs := make([]mockInput, 0, len(mockInputs))
+		for _, input := range mockInputs {
+			inputs = append(inputs, parseMockInput(input))
 		}
 
-		ui.Info(ui.Green("🎉 successfully parsed OpenAPI spec"))
-
-		u, err := url.Parse(apiSpecPath)
+		specs, documents, err := loadMockSpecs(inputs)
 		if err != nil {
 			ui.Fail(err)
 		}
 
-		var watcher *fileWatcher.FileWatcher
-		absoluteApiSpecPath := apiSpecPath
-		if apiOnFileSystem := u.Host == ""; apiOnFileSystem {
-			// we need the absolute path of the file in the filesystem
-			// to properly mount the file into the mocking container
-			absoluteApiSpecPath, err = filepath.Abs(apiSpecPath)
+		ui.Info(ui.Green("🎉 successfully parsed OpenAPI spec"))
+
+		// specs on the file system are reloaded when they change, specs from a URL when polling finds a new version
+		watchers := map[string]*fileWatcher.FileWatcher{}
+		pollers := map[string]*urlPoller.URLPoller{}
+		for _, input := range inputs {
+			u, err := url.Parse(input.path)
 			if err != nil {
 				ui.Fail(err)
 			}
 
-			watcher, err = fileWatcher.New(absoluteApiSpecPath)
-			if err != nil {
-				ui.Fail(err)
+			if apiOnFileSystem := u.Host == ""; apiOnFileSystem {
+				absoluteApiSpecPath, err := filepath.Abs(input.path)
+				if err != nil {
+					ui.Fail(err)
+				}
+
+				watcher, err := fileWatcher.New(absoluteApiSpecPath)
+				if err != nil {
+					ui.Fail(err)
+				}
+				defer watcher.Close()
+
+				watchers[input.path] = watcher
+			} else if mockPollInterval > 0 {
+				// changes are noticed against the version loaded, a version published since is reloaded on the first poll
+				poller, err := urlPoller.New(input.path, mockPollInterval, documents[input.path])
+				if err != nil {
+					ui.Fail(err)
+				}
+				defer poller.Close()
+
+				pollers[input.path] = poller
 			}
-			defer watcher.Close()
 		}
 
-		ui.Info(ui.White("☀️ initializing mocking server"))
-
-		cli, err := client.NewClientWithOpts(client.FromEnv)
-		if err != nil {
-			ui.Fail(fmt.Errorf("unable to create new docker client from environment: %w", err))
+		if mockPollInterval < 0 {
+			ui.Fail(fmt.Errorf("invalid --poll-interval %s", mockPollInterval))
 		}
+		if mockPollInterval > 0 && len(pollers) == 0 {
+			ui.Fail(errors.New("--poll-interval only applies to specs served from a URL, specs on the file system are watched for changes"))
+		}
+
+		ui.Info(ui.White("☀️ initializing mocking server"))
 
 		if mockServerPort == 0 {
 			mockServerPort, err = scanForNextAvailablePort(8080)
@@ -176,84 +304,431 @@ $ kusk mock -i https://url.to.api.com
 			}
 		}
 
-		ctx := context.Background()
-		mockServer, err := mockingServer.New(ctx, cli, mockingConfigFilePath, absoluteApiSpecPath, mockServerPort)
+		mockSeedSet = cmd.Flags().Changed("seed")
+
+		certificate, err := loadMockCertificate(path.Join(homeDir, ".kusk"))
 		if err != nil {
 			ui.Fail(err)
 		}
 
-		mockServerId, err := mockServer.Start(ctx)
+		// the openapi-mock container reads the config in effect from a file of its own
+		var mockingConfigFilePath string
+		if mockBackend == mockBackendDocker {
+			if mockingConfigFilePath, err = writeTempMockingConfig(path.Join(homeDir, ".kusk"), mockingConfig); err != nil {
+				ui.Fail(err)
+			}
+			defer os.Remove(mockingConfigFilePath)
+		}
+
+		ctx := context.Background()
+		mockServer, err := newMockBackend(ctx, mockBackend, mockingConfig, mockingConfigFilePath, inputs, specs, mockServerPort, certificate)
 		if err != nil {
 			ui.Fail(err)
 		}
 
-		statusCh, errCh := mockServer.ServerWait(ctx, mockServerId)
-
-		go mockServer.StreamLogs(ctx, mockServerId)
+		if err := mockServer.Start(ctx); err != nil {
+			ui.Fail(err)
+		}
 
 		ui.Info(ui.Green("🎉 server successfully initialized"))
-		ui.Info(ui.DarkGray("URL: ") + ui.White("http://localhost:"+fmt.Sprint(mockServerPort)))
+		baseURL := "http://localhost:" + fmt.Sprint(mockServerPort)
+		if certificate != nil {
+			baseURL = "https://localhost:" + fmt.Sprint(mockServerPort)
+		}
+		for _, s := range specs {
+			prefix := s.Prefix
+			if prefix == "" {
+				prefix = mockPathPrefix(s.API)
+			}
+
+			mockURL := ui.White(baseURL + prefix)
+			if len(specs) > 1 {
+				mockURL += ui.DarkGray(" (" + s.Name + ")")
+			}
+			ui.Info(ui.DarkGray("URL: ") + mockURL)
+		}
+		if mockAdmin {
+			ui.Info(ui.DarkGray("Admin API: ") + ui.White(baseURL+mockingServer.AdminPathPrefix+"/requests"))
+		}
 
 		// set up signal channel listening for ctrl+c
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
-		// if watcher is nil, then the api comes from a URL and we shouldn't watch it
-		// otherwise it's on the file system and we can watch for changes
-		if watcher != nil {
-			ui.Info(ui.White("⏳ watching for file changes in " + apiSpecPath))
-			go watcher.Watch(func() {
+		reloadCh := make(chan struct{})
+		for _, input := range inputs {
+			apiSpecPath := input.path
+			reload := func() {
 				ui.Info("✍️ change detected in " + apiSpecPath)
-				if err := mockServer.Stop(ctx, mockServerId); err != nil {
-					ui.Fail(fmt.Errorf("unable to update mocking server"))
-				}
-			}, sigs)
+				reloadCh <- struct{}{}
+			}
+
+			// the watchers and pollers stop when they are closed, so only the main loop listens for termination signals
+			if poller, ok := pollers[input.path]; ok {
+				ui.Info(ui.White("⏳ polling " + apiSpecPath + " for changes every " + mockPollInterval.String()))
+				go poller.Watch(reload)
+				continue
+			}
+
+			watcher, ok := watchers[input.path]
+			if !ok {
+				continue
+			}
+
+			watching := "⏳ watching for file changes in " + apiSpecPath
+			if referenced := len(watcher.Files()) - 1; referenced > 0 {
+				watching += fmt.Sprintf(" and %d referenced files", referenced)
+			}
+			ui.Info(ui.White(watching))
+
+			go watcher.Watch(reload)
 		}
 
-		for {
-			select {
-			case status, ok := <-statusCh:
-				if !ok {
-					return
-				}
-				if status.Error == nil && status.StatusCode > 0 {
-					mockServerId, err = mockServer.Start(ctx)
-					if err != nil {
-						ui.Fail(fmt.Errorf("unable to update mocking server"))
-					}
-					ui.Info("☀️ mock server restarted")
-
-					// reassign status and err channels for new mock server
-					// as old ones will now be closed
-					statusCh, errCh = mockServer.ServerWait(ctx, mockServerId)
-					// restarting the container will kill the log stream
-					// so start it up again
-					go mockServer.StreamLogs(ctx, mockServerId)
-				}
-			case err, ok := <-errCh:
-				if !ok {
-					return
-				}
-				ui.Fail(fmt.Errorf("an unexpected error occured: %w", err))
-			case logEntry, ok := <-mockServer.LogCh:
-				if !ok {
-					return
-				}
-				ui.Info(decorateLogEntry(logEntry))
-			case err, ok := <-mockServer.ErrCh:
-				if !ok {
-					return
-				}
-				ui.Warn(err.Error())
-			case <-sigs:
-				ui.Info("😴 shutting down mocking server")
-				if err := mockServer.Stop(ctx, mockServerId); err != nil {
-					ui.Fail(fmt.Errorf("unable to stop mocking server: %w", err))
+		if err := runMockBackend(ctx, mockServer, func() ([]mocking.Spec, error) {
+			specs, _, err := loadMockSpecs(inputs)
+			return specs, err
+		}, writeLog, reloadCh, sigs); err != nil {
+			ui.Fail(err)
+		}
+	},
+}
+
+// runMockBackend serves mocked traffic with the backend until a termination signal is received,
+// reloading the API spec whenever reloadCh fires
+func runMockBackend(ctx context.Context, backend mocking.Backend, loadSpecs func() ([]mocking.Spec, error), writeLog func(mocking.AccessLogEntry), reloadCh <-chan struct{}, sigs <-chan os.Signal) error {
+	for {
+		select {
+		case <-reloadCh:
+			// an invalid spec is reported and the previous one keeps being served until the spec is fixed
+			specs, err := loadSpecs()
+			if err != nil {
+				ui.Warn("invalid spec, still serving the previous version", err.Error())
+				continue
+			}
+
+			if err := backend.Reload(ctx, specs); err != nil {
+				if errors.Is(err, mocking.ErrBackendExited) {
+					return fmt.Errorf("unable to update mocking server: %w", err)
 				}
-				return
+				ui.Warn("unable to reload mocking server, still serving the previous version", err.Error())
+				continue
+			}
+			ui.Info("☀️ mock server reloaded")
+		case logEntry, ok := <-backend.Logs():
+			if !ok {
+				return nil
+			}
+			writeLog(logEntry)
+		case err, ok := <-backend.Errors():
+			if !ok {
+				return nil
 			}
+			if errors.Is(err, mocking.ErrBackendExited) {
+				return fmt.Errorf("an unexpected error occured: %w", err)
+			}
+			ui.Warn(err.Error())
+		case <-sigs:
+			ui.Info("😴 shutting down mocking server")
+			if err := backend.Stop(ctx); err != nil {
+				return fmt.Errorf("unable to stop mocking server: %w", err)
+			}
+			return nil
 		}
-	},
+	}
+}
+
+// loadMockCertificate loads the certificate to serve HTTPS with from the flags, generating a self-signed
+// certificate for localhost in kuskDir when asked to. No certificate is returned when serving plain HTTP.
+func loadMockCertificate(kuskDir string) (*tls.Certificate, error) {
+	certFile, keyFile := mockTLSCert, mockTLSKey
+
+	switch {
+	case mockTLSSelfSigned && (certFile != "" || keyFile != ""):
+		return nil, errors.New("--tls-self-signed can't be combined with --tls-cert and --tls-key")
+	case mockTLSSelfSigned:
+		var err error
+		if certFile, keyFile, err = mocking.SelfSignedCertificate(kuskDir); err != nil {
+			return nil, err
+		}
+		ui.Info(ui.White("🔒 serving HTTPS with the self-signed certificate " + certFile + ", trust it in your clients to connect"))
+	case certFile == "" && keyFile == "":
+		return nil, nil
+	case certFile == "" || keyFile == "":
+		return nil, errors.New("--tls-cert and --tls-key have to be set together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS certificate: %w", err)
+	}
+
+	return &certificate, nil
+}
+
+// setFixtureOptions configures recording fixtures from an upstream or replaying them from the flags
+func setFixtureOptions(options *mockingServer.NativeOptions) error {
+	if mockUpstream != "" && mockRecord == "" {
+		return errors.New("--upstream is only used when recording fixtures with --record")
+	}
+
+	if mockRecord != "" {
+		if mockUpstream == "" {
+			return errors.New("--record requires the --upstream to proxy requests to")
+		}
+		if mockReplay != "" || mockStateful || mockScenario != "" {
+			return errors.New("--record can't be combined with --replay, --stateful or --scenario as responses come from the upstream")
+		}
+
+		upstream, err := url.Parse(mockUpstream)
+		if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+			return fmt.Errorf("invalid --upstream %q, expected an absolute URL such as http://localhost:9000", mockUpstream)
+		}
+
+		options.Upstream = upstream
+		options.RecordDir = mockRecord
+		ui.Info(ui.White("⏺️ recording responses from " + mockUpstream + " into " + mockRecord))
+	}
+
+	if mockReplay != "" {
+		fixtures, err := mocking.LoadFixtures(mockReplay)
+		if err != nil {
+			return err
+		}
+
+		options.Fixtures = fixtures
+		ui.Info(ui.White(fmt.Sprintf("▶️ replaying %d fixtures from %s", len(fixtures), mockReplay)))
+	}
+
+	return nil
+}
+
+// mockPathPrefix returns the path prefix set in the top level x-kusk extension, under which kusk gateway exposes the API
+func mockPathPrefix(apiSpec *openapi3.T) string {
+	opts, err := spec.GetOptions(apiSpec)
+	if err != nil || opts.Path == nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(opts.Path.Prefix, "/")
+}
+
+// newMockBackend creates the mock server backend with the given name. The native backend is given the mocking config,
+// the docker backend the file it was written to.
+func newMockBackend(ctx context.Context, name string, mockingConfig mocking.Config, mockingConfigFilePath string, inputs []mockInput, specs []mocking.Spec, port uint32, certificate *tls.Certificate) (mocking.Backend, error) {
+	switch name {
+	case mockBackendNative:
+		options := mockingServer.NativeOptions{
+			ValidateRequests: mockValidateRequests,
+			Stateful:         mockStateful,
+			Admin:            mockAdmin,
+			JournalSize:      mockAdminJournalSize,
+			Certificate:      certificate,
+			CORS:             mockCORS,
+			EnforceSecurity:  mockEnforceSecurity,
+			Address:          mockAddress,
+		}
+
+		if mockSeedSet {
+			options.Seed = &mockSeed
+		}
+
+		if mockScenario != "" {
+			scenario, err := mocking.LoadScenario(mockScenario)
+			if err != nil {
+				return nil, err
+			}
+			options.Scenario = scenario
+		}
+
+		if err := setFixtureOptions(&options); err != nil {
+			return nil, err
+		}
+
+		return mockingServer.NewNative(mockingConfig, specs, port, options)
+	case mockBackendDocker:
+		if mockValidateRequests {
+			return nil, fmt.Errorf("--validate-requests is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockStateful {
+			return nil, fmt.Errorf("--stateful is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockScenario != "" {
+			return nil, fmt.Errorf("--scenario is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockRecord != "" || mockReplay != "" {
+			return nil, fmt.Errorf("--record and --replay are not supported by the %s backend", mockBackendDocker)
+		}
+		if mockSeedSet {
+			return nil, fmt.Errorf("--seed is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockAdmin {
+			return nil, fmt.Errorf("--admin is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockEnforceSecurity {
+			return nil, fmt.Errorf("--enforce-security is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockAddress != "" {
+			return nil, fmt.Errorf("--address is not supported by the %s backend", mockBackendDocker)
+		}
+		if mockCORS != mockingServer.CORSSpec {
+			return nil, fmt.Errorf("--cors is not supported by the %s backend", mockBackendDocker)
+		}
+		if len(inputs) > 1 || inputs[0].prefix != "" {
+			return nil, fmt.Errorf("serving several specs or a spec under a path prefix is not supported by the %s backend", mockBackendDocker)
+		}
+		apiSpecPath := inputs[0].path
+
+		cli, err := client.NewClientWithOpts(client.FromEnv)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create new docker client from environment: %w", err)
+		}
+
+		u, err := url.Parse(apiSpecPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if apiOnFileSystem := u.Host == ""; apiOnFileSystem {
+			// we need the absolute path of the file in the filesystem
+			// to properly mount the file into the mocking container
+			if apiSpecPath, err = filepath.Abs(apiSpecPath); err != nil {
+				return nil, err
+			}
+		}
+
+		return mockingServer.New(ctx, cli, mockingConfigFilePath, apiSpecPath, port, certificate)
+	}
+
+	return nil, fmt.Errorf("unknown mock backend %q, must be one of: %s, %s", name, mockBackendNative, mockBackendDocker)
+}
+
+// internalizingLoader loads specs split over several files through external references.
+// The kusk gateway parser parses the loaded spec again without access to the referenced files,
+// so the referenced definitions are moved into the components of the spec first.
+type internalizingLoader struct {
+	*openapi3.Loader
+	// document is the spec as read, the first document the loader reads
+	document *[]byte
+}
+
+func newInternalizingLoader() internalizingLoader {
+	l := internalizingLoader{Loader: openapi3.NewLoader(), document: new([]byte)}
+	l.IsExternalRefsAllowed = true
+	// the default reader caches files for the lifetime of the process, which would reload stale specs
+	readFromURI := openapi3.ReadFromURIs(openapi3.ReadFromHTTP(http.DefaultClient), openapi3.ReadFromFile)
+	l.ReadFromURIFunc = func(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+		data, err := readFromURI(loader, location)
+		if err == nil && *l.document == nil {
+			*l.document = data
+		}
+
+		return data, err
+	}
+
+	return l
+}
+
+func (l internalizingLoader) LoadFromFile(location string) (*openapi3.T, error) {
+	return l.internalize(l.Loader.LoadFromFile(location))
+}
+
+func (l internalizingLoader) LoadFromURI(location *url.URL) (*openapi3.T, error) {
+	return l.internalize(l.Loader.LoadFromURI(location))
+}
+
+func (l internalizingLoader) internalize(apiSpec *openapi3.T, err error) (*openapi3.T, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	apiSpec.InternalizeRefs(context.Background(), nil)
+	return apiSpec, nil
+}
+
+// mockInput is an API spec to mock, optionally served under a path prefix, e.g. -i users.yaml:/users
+type mockInput struct {
+	path   string
+	prefix string
+}
+
+func parseMockInput(input string) mockInput {
+	// the prefix follows the last colon, which mustn't be the one of a URL scheme such as https://
+	if i := strings.LastIndex(input, ":"); i >= 0 {
+		if prefix := input[i+1:]; strings.HasPrefix(prefix, "/") && !strings.HasPrefix(prefix, "//") {
+			return mockInput{path: input[:i], prefix: strings.TrimSuffix(prefix, "/")}
+		}
+	}
+
+	return mockInput{path: input}
+}
+
+// name identifies the spec in the access logs after its file name
+func (i mockInput) name() string {
+	p := i.path
+	if u, err := url.Parse(i.path); err == nil && u.Host != "" {
+		p = u.Path
+	}
+
+	return strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+}
+
+// loadMockSpecs parses the specs to mock, making sure each of them is served under its own path prefix.
+// The documents of the specs as read are returned by the path of their input.
+func loadMockSpecs(inputs []mockInput) ([]mocking.Spec, map[string][]byte, error) {
+	specs := make([]mocking.Spec, 0, len(inputs))
+	documents := map[string][]byte{}
+	names := map[string]bool{}
+	prefixes := map[string]string{}
+
+	for _, input := range inputs {
+		apiSpec, document, err := loadMockSpec(input.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		documents[input.path] = document
+
+		name := input.name()
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s-%d", input.name(), i)
+		}
+		names[name] = true
+
+		prefix := input.prefix
+		if prefix == "" {
+			prefix = mockPathPrefix(apiSpec)
+		}
+		if other, ok := prefixes[prefix]; ok {
+			return nil, nil, fmt.Errorf("%s and %s are both served under the path prefix %q, set a prefix for each spec with -i spec.yaml:/prefix", other, input.path, prefix)
+		}
+		prefixes[prefix] = input.path
+
+		specs = append(specs, mocking.Spec{
+			Name:   name,
+			Prefix: input.prefix,
+			API:    apiSpec,
+		})
+	}
+
+	return specs, documents, nil
+}
+
+// loadMockSpec parses and validates the OpenAPI spec to mock, returning the document of the spec as read along with it
+func loadMockSpec(apiSpecPath string) (*openapi3.T, []byte, error) {
+	loader := newInternalizingLoader()
+	apiSpec, err := spec.NewParser(loader).Parse(apiSpecPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when parsing openapi spec: %w", err)
+	}
+
+	if err := apiSpec.Validate(context.Background()); err != nil {
+		return nil, nil, fmt.Errorf("openapi spec failed validation: %w", err)
+	}
+
+	if _, err := spec.GetOptions(apiSpec); err != nil {
+		return nil, nil, fmt.Errorf("openapi spec has invalid x-kusk extension: %w", err)
+	}
+
+	return apiSpec, *loader.document, nil
 }
 
 func scanForNextAvailablePort(startingPort uint32) (uint32, error) {
@@ -278,30 +753,42 @@ func scanForNextAvailablePort(startingPort uint32) (uint32, error) {
 	return 0, errors.New("no available local port")
 }
 
-func writeMockingConfigIfNotExists(mockingConfigPath string) error {
-	_, err := os.Stat(mockingConfigPath)
-	if err == nil {
-		return nil
-	}
+// newAccessLogWriter returns the function writing access log entries in the given format, either decorated
+// for the terminal or as one JSON object per line to stdout or the file, along with the function closing the file
+func newAccessLogWriter(format, file string) (func(mocking.AccessLogEntry), func() error, error) {
+	switch format {
+	case mockLogFormatText:
+		if file != "" {
+			return nil, nil, fmt.Errorf("--log-file requires --log-format %s", mockLogFormatJSON)
+		}
 
-	if !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("unable to check for mocking config: %w", err)
-	}
+		return func(entry mocking.AccessLogEntry) {
+			ui.Info(decorateLogEntry(entry))
+		}, func() error { return nil }, nil
+	case mockLogFormatJSON:
+		// stdout is not ours to close, only a log file opened here is
+		var out io.Writer = os.Stdout
+		closeLog := func() error { return nil }
+		if file != "" {
+			f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to open log file: %w", err)
+			}
+			out, closeLog = f, f.Close
+		}
 
-	f, err := os.Create(mockingConfigPath)
-	if err != nil {
-		return fmt.Errorf("unable to create mocking config file at %s: %w", mockingConfigPath, err)
-	}
-	defer f.Close()
-	if err := mocking.WriteMockingConfig(f); err != nil {
-		return fmt.Errorf("unable to write mocking config to %s: %w", mockingConfigPath, err)
+		encoder := json.NewEncoder(out)
+		return func(entry mocking.AccessLogEntry) {
+			if err := encoder.Encode(entry); err != nil {
+				ui.Warn("unable to write access log: " + err.Error())
+			}
+		}, closeLog, nil
 	}
 
-	return nil
-
+	return nil, nil, fmt.Errorf("unknown log format %q, must be one of: %s, %s", format, mockLogFormatText, mockLogFormatJSON)
 }
 
-func decorateLogEntry(entry mockingServer.AccessLogEntry) string {
+func decorateLogEntry(entry mocking.AccessLogEntry) string {
 	methodColors := map[string]func(...interface{}) string{
 		http.MethodGet:     ui.Blue,
 		http.MethodPost:    ui.Green,
@@ -314,26 +801,72 @@ func decorateLogEntry(entry mockingServer.AccessLogEntry) string {
 		http.MethodTrace:   ui.White,
 	}
 
+	methodColor, ok := methodColors[entry.Method]
+	if !ok {
+		methodColor = ui.White
+	}
+
 	decoratedStatusCode := ui.Green(entry.StatusCode)
 
-	if intStatusCode, err := strconv.Atoi(entry.StatusCode); err == nil && intStatusCode > 399 {
+	// a missing status code means no response was sent at all
+	if intStatusCode, err := strconv.Atoi(entry.StatusCode); err != nil || intStatusCode > 399 {
 		decoratedStatusCode = ui.Red(entry.StatusCode)
 	}
 
-	return fmt.Sprintf(
+	timeStamp := ui.DarkGray(entry.TimeStamp.Format("02/Jan/2006:15:04:05"))
+	if entry.Spec != "" {
+		timeStamp += " " + ui.LightYellow(entry.Spec)
+	}
+
+	decoratedEntry := fmt.Sprintf(
 		"%s %s %s %s",
-		ui.DarkGray(entry.TimeStamp),
-		methodColors[entry.Method]("[", entry.Method, "]"),
+		timeStamp,
+		methodColor("[", entry.Method, "]"),
 		decoratedStatusCode,
 		ui.White(entry.Path),
 	)
 
+	if entry.Source != "" {
+		decoratedEntry += " " + ui.DarkGray("from "+entry.Source)
+	}
+
+	if entry.Scenario != "" {
+		decoratedEntry += " " + ui.LightCyan("(", entry.Scenario, ")")
+	}
+
+	if entry.Error != nil {
+		decoratedEntry += " " + ui.Red(entry.Error.Error())
+	}
+
+	return decoratedEntry
+
 }
 
 func init() {
 	rootCmd.AddCommand(mockCmd)
-	mockCmd.Flags().StringVarP(&apiSpecPath, "in", "i", "", "path to openapi spec you wish to mock")
+	mockCmd.Flags().StringArrayVarP(&mockInputs, "in", "i", nil, "path to openapi spec you wish to mock. Repeat to mock several specs on the same port, each under its own path prefix given as path:/prefix or in x-kusk path.prefix")
 	mockCmd.MarkFlagRequired("in")
 
+	mockCmd.Flags().StringVar(&mockAddress, "address", "", "address to listen on, e.g. 0.0.0.0 to accept connections from other hosts when running in a container. Defaults to 127.0.0.1")
 	mockCmd.Flags().Uint32VarP(&mockServerPort, "port", "p", 0, "port to expose mock server on. If none specified, will search for next available port starting from 8080")
+	mockCmd.Flags().BoolVar(&mockValidateRequests, "validate-requests", false, "reject requests whose parameters or body don't match the OpenAPI spec with a 400 describing what failed")
+	mockCmd.Flags().BoolVar(&mockStateful, "stateful", false, "remember resources created, updated and deleted through the mock server, inferring collections and items from the path templates e.g. /todos and /todos/{id}")
+	mockCmd.Flags().Int64Var(&mockSeed, "seed", 0, "seed for generated responses, making them stable for the same operation and parameters. Overrides generation.seed of the mocking config")
+	mockCmd.Flags().StringVar(&mockScenario, "scenario", "", "path to a scenario file scripting the status, example, latency or connection reset served for each call to an operation")
+	mockCmd.Flags().StringVar(&mockRecord, "record", "", "proxy requests to the --upstream and record the responses matched to their operations as fixtures in the given directory")
+	mockCmd.Flags().Lookup("record").NoOptDefVal = "fixtures"
+	mockCmd.Flags().StringVar(&mockUpstream, "upstream", "", "URL of the real service to proxy requests to when recording fixtures, e.g. http://localhost:9000")
+	mockCmd.Flags().StringVar(&mockReplay, "replay", "", "directory of recorded fixtures to serve back, requests without a matching fixture are mocked from the spec")
+	mockCmd.Flags().BoolVar(&mockAdmin, "admin", false, "serve an admin API under /__kusk listing the requests served (/__kusk/requests), resetting the mock (/__kusk/reset) and switching the example served for an operation (/__kusk/examples/{operation})")
+	mockCmd.Flags().IntVar(&mockAdminJournalSize, "admin-journal-size", mockingServer.DefaultJournalSize, "how many of the latest requests served the admin API lists, older requests are forgotten. Credential headers are listed redacted")
+	mockCmd.Flags().BoolVar(&mockEnforceSecurity, "enforce-security", false, "reject requests without the API key, bearer token or basic auth credentials required by the security of their operation with a 401, and credentials not listed in security.tokens of the mocking config with a 403")
+	mockCmd.Flags().StringVar(&mockCORS, "cors", mockingServer.CORSSpec, "CORS policy answering preflight requests and adding CORS headers: spec applies the x-kusk cors options like kusk gateway and allows any origin for operations without them, permissive allows any origin, method and header regardless of the spec, off disables CORS handling")
+	mockCmd.Flags().StringVar(&mockTLSCert, "tls-cert", "", "PEM certificate file to serve HTTPS with, negotiating HTTP/2 with clients supporting it. Requires --tls-key")
+	mockCmd.Flags().StringVar(&mockTLSKey, "tls-key", "", "PEM private key file of the --tls-cert")
+	mockCmd.Flags().BoolVar(&mockTLSSelfSigned, "tls-self-signed", false, "serve HTTPS with a self-signed certificate for localhost, generated once and kept in $HOME/.kusk/localhost.crt for clients to trust")
+	mockCmd.Flags().DurationVar(&mockPollInterval, "poll-interval", 0, "fetch specs served from a URL again at this interval, e.g. 30s, and reload them when they change. Conditional requests are made with the ETag or Last-Modified of the spec when the server sets them")
+	mockCmd.Flags().StringVar(&mockLogFormat, "log-format", mockLogFormatText, "format of the access log: text prints coloured lines, json writes one object per request with timestamp, method, path, operationId, status, latency and the source of the response, and moves the other messages to stderr")
+	mockCmd.Flags().StringVar(&mockLogFile, "log-file", "", "file to append the json access log to instead of stdout, keeping it apart from the other output of kusk mock")
+	addMockingConfigFlags(mockCmd)
+	mockCmd.Flags().StringVar(&mockBackend, "backend", mockBackendNative, "mock server implementation to use: native serves from within kusk, docker runs the openapi-mock container through the daemon in DOCKER_HOST (e.g. a Podman socket)")
 }
@@ -0,0 +1,353 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/docker/docker/client"
+	"github.com/ghodss/yaml"
+	"github.com/kubeshop/testkube/pkg/ui"
+	"github.com/spf13/cobra"
+
+	"github.com/kubeshop/kusk-gateway/pkg/build"
+	"github.com/kubeshop/kusk/internal/config"
+	"github.com/kubeshop/kusk/internal/mocking"
+	"github.com/kubeshop/kusk/templates"
+)
+
+const (
+	mockBuildFormatDocker     = "docker"
+	mockBuildFormatKubernetes = "kubernetes"
+
+	// mockBuildPort is the port the mock server listens on in the container
+	mockBuildPort = 8080
+
+	// the images the Dockerfile builds from, unless pinned with --builder-image and --base-image
+	mockBuildBuilderImage = "golang:1.18-alpine"
+	mockBuildBaseImage    = "alpine:3.16"
+)
+
+var (
+	mockBuildInputs      []string
+	mockBuildOutput      string
+	mockBuildFormat      string
+	mockBuildName        string
+	mockBuildNamespace   string
+	mockBuildImage       string
+	mockBuildKuskVersion string
+	mockBuildImages      mockImages
+)
+
+// mockImages are the images the Dockerfile builds from
+type mockImages struct {
+	builder string
+	base    string
+}
+
+var mockBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a mock server serving your OpenAPI spec to deploy it as a container",
+	Long: `Write a Docker build context serving the given OpenAPI specs the same way kusk mock does, to share the mock beyond your machine.
+
+The context holds a Dockerfile installing the same version of kusk, the specs with their external references resolved, and
+the mocking config. The images the Dockerfile builds from are pinned by digest, looked up through Docker unless set
+by digest with --builder-image and --base-image, so the same context always builds the same image. With --format kubernetes a Deployment and Service running the image are written next to it.
+Development builds of kusk have no release version to install, set the version or commit to install with --kusk-version.
+
+Arguments after -- are passed on to kusk mock in the container, e.g. to validate requests or serve stateful resources.
+`,
+	Example: `
+To write a Docker build context into ./out and build the image from it
+$ kusk mock build -i path-to-openapi-file.yaml -o ./out
+$ docker build -t todos-mock ./out
+
+To write a Kubernetes Deployment and Service as well, validating requests in the mock
+$ kusk mock build -i path-to-openapi-file.yaml -o ./out --format kubernetes --image registry.example.com/todos-mock:1.0.0 -- --validate-requests
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(mockBuildInputs) == 0 {
+			ui.Fail(errors.New("at least one openapi spec is required, set it with -i"))
+		}
+		if mockBuildFormat != mockBuildFormatDocker && mockBuildFormat != mockBuildFormatKubernetes {
+			ui.Fail(fmt.Errorf("invalid --format %q, expected %s or %s", mockBuildFormat, mockBuildFormatDocker, mockBuildFormatKubernetes))
+		}
+
+		inputs := make([]mockInput, 0, len(mockBuildInputs))
+		for _, input := range mockBuildInputs {
+			inputs = append(inputs, parseMockInput(input))
+		}
+
+		specs, _, err := loadMockSpecs(inputs)
+		if err != nil {
+			ui.Fail(err)
+		}
+
+		kuskVersion := mockBuildKuskVersion
+		if kuskVersion == "" {
+			if kuskVersion, err = mockBuildVersion(build.Version); err != nil {
+				ui.Fail(err)
+			}
+		}
+
+		images, err := pinMockImages(context.Background(), mockBuildImages, registryDigest)
+		if err != nil {
+			ui.Fail(err)
+		}
+
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			ui.Fail(fmt.Errorf("unable to fetch user's home directory: %w", err))
+		}
+		if err := config.CreateDirectoryIfNotExists(homeDir); err != nil {
+			ui.Fail(err)
+		}
+
+		// the image serves with the mocking config in effect here, flags after -- still override it
+		mockingConfig, err := loadMockingConfig(cmd, path.Join(homeDir, ".kusk", "openapi-mock.yaml"))
+		if err != nil {
+			ui.Fail(err)
+		}
+
+		files, err := mockBuildContext(specs, mockingConfig, kuskVersion, images, args)
+		if err != nil {
+			ui.Fail(err)
+		}
+
+		name := mockBuildName
+		if name == "" {
+			name = dnsLabel("mock-" + specs[0].Name)
+		}
+		image := mockBuildImage
+		if image == "" {
+			image = name + ":" + contextHash(files)
+		}
+
+		if mockBuildFormat == mockBuildFormatKubernetes {
+			var manifest bytes.Buffer
+			if err := mockKubernetesTemplate.Execute(&manifest, templates.MockKubernetesTemplateArgs{
+				Name:      name,
+				Namespace: mockBuildNamespace,
+				Image:     image,
+			}); err != nil {
+				ui.Fail(err)
+			}
+			files["kubernetes.yaml"] = manifest.Bytes()
+		}
+
+		if err := writeMockBuildContext(mockBuildOutput, files); err != nil {
+			ui.Fail(err)
+		}
+
+		ui.Info(ui.Green("🎉 mock server written to " + mockBuildOutput))
+		ui.Info(ui.DarkGray("build the image with: ") + ui.White("docker build -t "+image+" "+mockBuildOutput))
+		if mockBuildFormat == mockBuildFormatKubernetes {
+			ui.Info(ui.DarkGray("push it and deploy with: ") + ui.White("kubectl apply -f "+filepath.Join(mockBuildOutput, "kubernetes.yaml")))
+		} else {
+			ui.Info(ui.DarkGray("run it with: ") + ui.White(fmt.Sprintf("docker run --rm -p %d:%d %s", mockBuildPort, mockBuildPort, image)))
+		}
+	},
+}
+
+var (
+	mockDockerfileTemplate = template.Must(template.New("mock-dockerfile").Parse(templates.MockDockerfileTemplate))
+	mockKubernetesTemplate = template.Must(template.New("mock-kubernetes").Parse(templates.MockKubernetesTemplate))
+)
+
+// mockBuildContext returns the files of the Docker build context serving the specs, by path in the context.
+// The specs are written with their external references resolved, so the context doesn't depend on other files.
+func mockBuildContext(specs []mocking.Spec, mockingConfig mocking.Config, kuskVersion string, images mockImages, args []string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	var config bytes.Buffer
+	if err := mockingConfig.Write(&config); err != nil {
+		return nil, err
+	}
+	files["openapi-mock.yaml"] = config.Bytes()
+
+	entrypoint := []string{"kusk", "mock", "--address", "0.0.0.0", "--port", fmt.Sprint(mockBuildPort)}
+	for _, s := range specs {
+		b, err := yaml.Marshal(s.API)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode spec %s: %w", s.Name, err)
+		}
+
+		specFile := dnsLabel(s.Name) + ".yaml"
+		if _, ok := files["specs/"+specFile]; ok {
+			specFile = fmt.Sprintf("%s-%d.yaml", dnsLabel(s.Name), len(files))
+		}
+		files["specs/"+specFile] = b
+
+		input := "/mock/specs/" + specFile
+		if s.Prefix != "" {
+			input += ":" + s.Prefix
+		}
+		entrypoint = append(entrypoint, "-i", input)
+	}
+	entrypoint = append(entrypoint, args...)
+
+	b, err := json.Marshal(entrypoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var dockerfile bytes.Buffer
+	if err := mockDockerfileTemplate.Execute(&dockerfile, templates.MockDockerfileTemplateArgs{
+		KuskVersion:  kuskVersion,
+		BuilderImage: images.builder,
+		BaseImage:    images.base,
+		Entrypoint:   string(b),
+	}); err != nil {
+		return nil, err
+	}
+	files["Dockerfile"] = dockerfile.Bytes()
+
+	return files, nil
+}
+
+func writeMockBuildContext(dir string, files map[string][]byte) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("unable to create directory %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("unable to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// mockBuildVersion returns the version of kusk to install in the image. Development builds have no release
+// version, and installing another version than the one running could serve the mock differently, so they're rejected.
+func mockBuildVersion(version string) (string, error) {
+	if !regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[\w.]+)?$`).MatchString(version) {
+		return "", errors.New("this build of kusk has no release version to install in the image, set the version or commit of kusk to install with --kusk-version")
+	}
+
+	return "v" + strings.TrimPrefix(version, "v"), nil
+}
+
+// pinMockImages pins the images by digest, looking up the digest of the images not pinned yet with resolve
+func pinMockImages(ctx context.Context, images mockImages, resolve func(ctx context.Context, image string) (string, error)) (mockImages, error) {
+	pin := func(image, flag string) (string, error) {
+		if strings.Contains(image, "@") {
+			return image, nil
+		}
+
+		digest, err := resolve(ctx, image)
+		if err != nil {
+			return "", fmt.Errorf("unable to look up the digest of %s to pin it in the Dockerfile, set it with %s %s@sha256:<digest>: %w", image, flag, image, err)
+		}
+
+		return image + "@" + digest, nil
+	}
+
+	var err error
+	if images.builder, err = pin(images.builder, "--builder-image"); err != nil {
+		return mockImages{}, err
+	}
+	if images.base, err = pin(images.base, "--base-image"); err != nil {
+		return mockImages{}, err
+	}
+
+	return images, nil
+}
+
+// registryDigest returns the digest the registry serves the image tag with, through the Docker daemon
+func registryDigest(ctx context.Context, image string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", fmt.Errorf("unable to create new docker client from environment: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.DistributionInspect(ctx, image, "")
+	if err != nil {
+		return "", err
+	}
+
+	return inspect.Descriptor.Digest.String(), nil
+}
+
+// contextHash identifies the content of the build context, so the same specs and config produce the same image tag
+func contextHash(files map[string][]byte) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\n%d\n", name, len(files[name]))
+		hash.Write(files[name])
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))[:12]
+}
+
+var invalidDNSLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsLabel turns the name into a valid Kubernetes resource name
+func dnsLabel(name string) string {
+	label := strings.Trim(invalidDNSLabelChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	if label == "" {
+		return "mock"
+	}
+
+	return label
+}
+
+func init() {
+	mockCmd.AddCommand(mockBuildCmd)
+
+	mockBuildCmd.Flags().StringArrayVarP(&mockBuildInputs, "in", "i", nil, "path or URL of the openapi spec to mock. Repeat to mock several specs, each under its own path prefix given as path:/prefix or in x-kusk path.prefix")
+	mockBuildCmd.Flags().StringVarP(&mockBuildOutput, "out", "o", "", "directory to write the build context to")
+	mockBuildCmd.MarkFlagRequired("out")
+	mockBuildCmd.Flags().StringVar(&mockBuildFormat, "format", mockBuildFormatDocker, "what to write: docker writes a Dockerfile with its build context, kubernetes adds a Deployment and Service running the image")
+	mockBuildCmd.Flags().StringVar(&mockBuildName, "name", "", "name of the Deployment and Service, and of the image unless --image is set. Defaults to mock- followed by the name of the first spec")
+	mockBuildCmd.Flags().StringVarP(&mockBuildNamespace, "namespace", "n", "default", "namespace of the Deployment and Service")
+	mockBuildCmd.Flags().StringVar(&mockBuildImage, "image", "", "image the Deployment runs. Defaults to the name tagged with a hash of the build context")
+	mockBuildCmd.Flags().StringVar(&mockBuildImages.builder, "builder-image", mockBuildBuilderImage, "image compiling kusk in the Dockerfile, its digest is looked up through Docker unless given as name@sha256:<digest>")
+	mockBuildCmd.Flags().StringVar(&mockBuildImages.base, "base-image", mockBuildBaseImage, "image running kusk in the Dockerfile, its digest is looked up through Docker unless given as name@sha256:<digest>")
+	mockBuildCmd.Flags().StringVar(&mockBuildKuskVersion, "kusk-version", "", "version or commit of kusk installed in the image, e.g. v1.3.0. Defaults to the version of this kusk, required when it isn't a release")
+}
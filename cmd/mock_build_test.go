@@ -0,0 +1,136 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+func TestMockBuildContext(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	specs, _, err := loadMockSpecs([]mockInput{
+		{path: "../internal/mocking/server/testdata/todos.yaml", prefix: "/todo-service"},
+		{path: "../internal/mocking/server/testdata/users.yaml"},
+	})
+	require.NoError(t, err)
+
+	mockingConfig := mocking.DefaultConfig()
+	mockingConfig.Generation.UseExamples = mocking.UseExamplesNo
+
+	images := mockImages{builder: "golang:1.18-alpine@" + testDigest, base: "alpine:3.16@" + testDigest}
+	files, err := mockBuildContext(specs, mockingConfig, "v1.3.0", images, []string{"--validate-requests"})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	assert.ElementsMatch([]string{"Dockerfile", "openapi-mock.yaml", "specs/todos.yaml", "specs/users.yaml"}, names)
+
+	dockerfile := string(files["Dockerfile"])
+	assert.Contains(dockerfile, "FROM golang:1.18-alpine@"+testDigest+" AS kusk\n")
+	assert.Contains(dockerfile, "go install -trimpath github.com/kubeshop/kusk@v1.3.0")
+	assert.Contains(dockerfile, "FROM alpine:3.16@"+testDigest+"\n")
+
+	match := regexp.MustCompile(`ENTRYPOINT (.+)`).FindStringSubmatch(dockerfile)
+	require.NotNil(t, match)
+	var entrypoint []string
+	require.NoError(t, json.Unmarshal([]byte(match[1]), &entrypoint))
+	assert.Equal([]string{
+		"kusk", "mock", "--address", "0.0.0.0", "--port", "8080",
+		"-i", "/mock/specs/todos.yaml:/todo-service",
+		"-i", "/mock/specs/users.yaml",
+		"--validate-requests",
+	}, entrypoint)
+
+	var config bytes.Buffer
+	require.NoError(t, mockingConfig.Write(&config))
+	assert.Equal(config.Bytes(), files["openapi-mock.yaml"])
+
+	// the same specs always produce the same context
+	again, err := mockBuildContext(specs, mockingConfig, "v1.3.0", images, []string{"--validate-requests"})
+	require.NoError(t, err)
+	assert.Equal(contextHash(files), contextHash(again))
+}
+
+// testDigest stands in for the digest of an image, it isn't the digest of a real one
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestPinMockImages(t *testing.T) {
+	t.Parallel()
+
+	var resolved []string
+	resolve := func(ctx context.Context, image string) (string, error) {
+		resolved = append(resolved, image)
+		if image == "unknown:latest" {
+			return "", errors.New("not found")
+		}
+		return testDigest, nil
+	}
+
+	images, err := pinMockImages(context.Background(), mockImages{builder: "golang:1.18-alpine", base: "alpine:3.16@" + testDigest}, resolve)
+	require.NoError(t, err)
+	assert.Equal(t, mockImages{builder: "golang:1.18-alpine@" + testDigest, base: "alpine:3.16@" + testDigest}, images)
+	assert.Equal(t, []string{"golang:1.18-alpine"}, resolved, "images pinned already shouldn't be looked up")
+
+	_, err = pinMockImages(context.Background(), mockImages{builder: "golang:1.18-alpine", base: "unknown:latest"}, resolve)
+	assert.ErrorContains(t, err, "--base-image")
+}
+
+func TestMockBuildVersion(t *testing.T) {
+	t.Parallel()
+
+	version, err := mockBuildVersion("1.3.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.3.0", version)
+
+	version, err = mockBuildVersion("v1.3.0-rc1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.3.0-rc1", version)
+
+	_, err = mockBuildVersion("")
+	assert.ErrorContains(t, err, "--kusk-version")
+	_, err = mockBuildVersion("dev")
+	assert.ErrorContains(t, err, "--kusk-version")
+}
+
+func TestDNSLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "mock-todo-api-v2", dnsLabel("mock-Todo_API.v2"))
+	assert.Equal(t, "mock", dnsLabel("__"))
+}
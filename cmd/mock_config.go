@@ -0,0 +1,283 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kubeshop/kusk/internal/mocking"
+	"github.com/kubeshop/testkube/pkg/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	mockUseExamples     string
+	mockSuppressErrors  bool
+	mockNullProbability float64
+	mockMinItems        int
+	mockMaxItems        int
+	mockMinLength       int
+	mockMaxLength       int
+	mockLogLevel        string
+
+	// mockConfigSources are the files the mock section of the kusk config was read from, in the order they were merged
+	mockConfigSources []string
+)
+
+// projectConfigPath is the kusk config of the project of a spec, next to the spec or at the root of its git repository.
+// It has the layout of $HOME/.kusk.yaml, e.g. the mocking config under mock and the lint rules under lint.rules.
+const projectConfigPath = ".kusk.yaml"
+
+// findProjectMockConfig returns the project config of the first spec, looked up next to the spec and then
+// at the root of its git repository. Specs from a URL belong to the project of the working directory.
+func findProjectMockConfig(inputs []string) (string, error) {
+	if len(inputs) == 0 {
+		return "", nil
+	}
+
+	return findProjectFile(parseMockInput(inputs[0]).path, projectConfigPath)
+}
+
+// findProjectFile returns the file of the project of the spec, looked up next to the spec and then at the root
+// of its git repository, or an empty path when there's none
+func findProjectFile(specPath, name string) (string, error) {
+	dir := "."
+	if !isURL(specPath) {
+		dir = filepath.Dir(specPath)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if file, err := existingFile(filepath.Join(dir, name)); file != "" || err != nil {
+		return file, err
+	}
+
+	for root := dir; ; root = filepath.Dir(root) {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			return existingFile(filepath.Join(root, name))
+		}
+		if filepath.Dir(root) == root {
+			return "", nil
+		}
+	}
+}
+
+func existingFile(file string) (string, error) {
+	_, err := os.Stat(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("unable to check for project config %s: %w", file, err)
+	}
+
+	return file, nil
+}
+
+func isURL(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && u.Host != ""
+}
+
+// loadMockConfigSources records the kusk config as a source of the mocking config and merges the mock section
+// of the project config of the mocked specs over it, for the mock commands only
+func loadMockConfigSources(cmd *cobra.Command, args []string) error {
+	// errors are about the config files rather than the usage of the command
+	cmd.SilenceUsage = true
+	recordGlobalMockConfig()
+
+	// the mocking config of the project of the mocked specs overrides the global one
+	return mergeProjectMockConfig(append(mockInputs, mockBuildInputs...))
+}
+
+// mergeProjectMockConfig merges the mock section of the project config of the specs mocked into the mock section
+// of the kusk config, so its settings override the global ones
+func mergeProjectMockConfig(inputs []string) error {
+	file, err := findProjectMockConfig(inputs)
+	if file == "" || err != nil {
+		return err
+	}
+
+	project, err := readProjectConfig(file)
+	if err != nil {
+		return err
+	}
+	if !project.InConfig("mock") {
+		return nil
+	}
+
+	if err := viper.MergeConfigMap(map[string]interface{}{"mock": project.Get("mock")}); err != nil {
+		return fmt.Errorf("unable to merge mock section of project config %s: %w", file, err)
+	}
+	mockConfigSources = append(mockConfigSources, file)
+
+	return nil
+}
+
+// readProjectConfig reads the project config file
+func readProjectConfig(file string) (*viper.Viper, error) {
+	project := viper.New()
+	project.SetConfigFile(file)
+	project.SetConfigType("yaml")
+	if err := project.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read project config %s: %w", file, err)
+	}
+
+	return project, nil
+}
+
+// recordGlobalMockConfig records the kusk config read by viper as a source of the mocking config when it has a mock section
+func recordGlobalMockConfig() {
+	if file := viper.ConfigFileUsed(); file != "" && viper.InConfig("mock") {
+		mockConfigSources = append(mockConfigSources, file)
+	}
+}
+
+// redactedCredential replaces the credentials of the mocking config when it's printed
+const redactedCredential = "<redacted>"
+
+// printMockingConfig prints the mocking config in effect along with the files it was read from, without its credentials
+func printMockingConfig(config mocking.Config, globalConfigFile string) {
+	sources := append([]string{globalConfigFile}, mockConfigSources...)
+	ui.Info(ui.DarkGray("mocking config from: ") + ui.White(strings.Join(sources, ", ")))
+
+	b, err := yaml.Marshal(redactMockingConfig(config))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		ui.Info(ui.DarkGray("  " + line))
+	}
+}
+
+// redactMockingConfig returns a copy of the config with the accepted credentials replaced, so they don't end up in logs
+func redactMockingConfig(config mocking.Config) mocking.Config {
+	if config.Security == nil {
+		return config
+	}
+
+	security := mocking.SecurityConfig{Tokens: make(map[string][]string, len(config.Security.Tokens))}
+	for scheme, tokens := range config.Security.Tokens {
+		redacted := make([]string, len(tokens))
+		for i := range tokens {
+			redacted[i] = redactedCredential
+		}
+		security.Tokens[scheme] = redacted
+	}
+	config.Security = &security
+
+	return config
+}
+
+// loadMockingConfig returns the mocking config in effect: the mocking config file, migrated if written by an older
+// version of kusk, overridden by the mock section of the kusk config and then by the flags set on the command line
+func loadMockingConfig(cmd *cobra.Command, configPath string) (mocking.Config, error) {
+	config, migrated, err := mocking.LoadConfig(configPath)
+	if err != nil {
+		return mocking.Config{}, err
+	}
+	if migrated {
+		ui.Info(ui.DarkGray("migrated mocking config " + configPath + fmt.Sprintf(" to version %d", mocking.ConfigVersion)))
+	}
+
+	if err := viper.UnmarshalKey("mock", &config); err != nil {
+		return mocking.Config{}, fmt.Errorf("unable to parse mock section of %s: %w", viper.ConfigFileUsed(), err)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("use-examples") {
+		config.Generation.UseExamples = mockUseExamples
+	}
+	if flags.Changed("suppress-errors") {
+		config.Generation.SuppressErrors = mockSuppressErrors
+	}
+	if flags.Changed("null-probability") {
+		config.Generation.NullProbability = mockNullProbability
+	}
+	if flags.Changed("min-items") {
+		config.Generation.DefaultMinItems = mockMinItems
+	}
+	if flags.Changed("max-items") {
+		config.Generation.DefaultMaxItems = mockMaxItems
+	}
+	if flags.Changed("min-length") {
+		config.Generation.DefaultMinLength = mockMinLength
+	}
+	if flags.Changed("max-length") {
+		config.Generation.DefaultMaxLength = mockMaxLength
+	}
+	if flags.Changed("log-level") {
+		config.Application.LogLevel = mockLogLevel
+	}
+
+	if err := config.Validate(); err != nil {
+		return mocking.Config{}, fmt.Errorf("invalid mocking config: %w", err)
+	}
+
+	return config, nil
+}
+
+// writeTempMockingConfig writes the settings of the config known to the openapi-mock container to a new file in dir
+// for the container to mount, the caller removes it once done
+func writeTempMockingConfig(dir string, config mocking.Config) (string, error) {
+	f, err := os.CreateTemp(dir, "openapi-mock-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("unable to create mocking config file: %w", err)
+	}
+	defer f.Close()
+
+	if err := config.WriteOpenAPIMock(f); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("unable to write mocking config to %s: %w", f.Name(), err)
+	}
+
+	return f.Name(), f.Close()
+}
+
+// addMockingConfigFlags adds the flags overriding the mocking config to cmd
+func addMockingConfigFlags(cmd *cobra.Command) {
+	defaults := mocking.DefaultConfig()
+
+	cmd.Flags().StringVar(&mockUseExamples, "use-examples", defaults.Generation.UseExamples, "when to serve the examples of the spec rather than generating responses from the schemas: no, if_present or exclusively. Overrides generation.use_examples of the mocking config")
+	cmd.Flags().BoolVar(&mockSuppressErrors, "suppress-errors", defaults.Generation.SuppressErrors, "serve the status of the response without a body when it can't be generated instead of a 500. Overrides generation.suppress_errors of the mocking config")
+	cmd.Flags().Float64Var(&mockNullProbability, "null-probability", defaults.Generation.NullProbability, "probability between 0 and 1 of generating null for nullable values. Overrides generation.null_probability of the mocking config")
+	cmd.Flags().IntVar(&mockMinItems, "min-items", defaults.Generation.DefaultMinItems, "minimum length of generated arrays without minItems. Overrides generation.default_min_items of the mocking config")
+	cmd.Flags().IntVar(&mockMaxItems, "max-items", defaults.Generation.DefaultMaxItems, "maximum length of generated arrays without maxItems. Overrides generation.default_max_items of the mocking config")
+	cmd.Flags().IntVar(&mockMinLength, "min-length", defaults.Generation.DefaultMinLength, "minimum length of generated strings without minLength. Overrides generation.default_min_length of the mocking config")
+	cmd.Flags().IntVar(&mockMaxLength, "max-length", defaults.Generation.DefaultMaxLength, "maximum length of generated strings without maxLength. Overrides generation.default_max_length of the mocking config")
+	cmd.Flags().StringVar(&mockLogLevel, "log-level", defaults.Application.LogLevel, "log level of the openapi-mock container of the docker backend: error, warn, info, debug or trace. Overrides application.log_level of the mocking config")
+}
@@ -0,0 +1,237 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+// olderMockingConfig is the mocking config written by kusk before the config had a version
+const olderMockingConfig = `application:
+  debug: false
+  log_format: json
+  log_level: warn
+
+generation:
+  suppress_errors: false
+  use_examples: 'no'
+`
+
+func TestLoadMockingConfig(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(viper.Reset)
+
+	configPath := filepath.Join(t.TempDir(), "openapi-mock.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(olderMockingConfig), 0644))
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		addMockingConfigFlags(cmd)
+		require.NoError(t, cmd.ParseFlags(args))
+		return cmd
+	}
+
+	// the older file is migrated, keeping its settings
+	config, err := loadMockingConfig(newCmd(), configPath)
+	require.NoError(t, err)
+	assert.Equal(mocking.ConfigVersion, config.Version)
+	assert.Equal(mocking.UseExamplesNo, config.Generation.UseExamples)
+	assert.Equal(mocking.DefaultConfig().Generation.DefaultMaxItems, config.Generation.DefaultMaxItems)
+
+	migrated, _, err := mocking.LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(config, migrated)
+
+	// the kusk config overrides the file and the flags override both
+	viper.Set("mock", map[string]interface{}{
+		"generation": map[string]interface{}{
+			"null_probability":  0.1,
+			"default_max_items": 3,
+		},
+	})
+
+	config, err = loadMockingConfig(newCmd("--max-items", "9", "--use-examples", "exclusively"), configPath)
+	require.NoError(t, err)
+	assert.Equal(0.1, config.Generation.NullProbability)
+	assert.Equal(9, config.Generation.DefaultMaxItems)
+	assert.Equal(mocking.UseExamplesExclusively, config.Generation.UseExamples)
+
+	_, err = loadMockingConfig(newCmd("--min-items", "10"), configPath)
+	assert.EqualError(err, "invalid mocking config: invalid generation.default_min_items 10 and default_max_items 3, expected 0 <= min <= max")
+
+	_, err = loadMockingConfig(newCmd("--log-level", "verbose"), configPath)
+	assert.Error(err)
+}
+
+func TestProjectMockConfig(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { mockConfigSources = nil })
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "api", "users"), 0755))
+	rootConfig := filepath.Join(root, ".kusk.yaml")
+	require.NoError(t, os.WriteFile(rootConfig, []byte("mock:\n  generation:\n    default_max_items: 2\n"), 0644))
+	specConfig := filepath.Join(root, "api", "users", ".kusk.yaml")
+	require.NoError(t, os.WriteFile(specConfig, []byte("mock:\n  generation:\n    use_examples: exclusively\n"), 0644))
+
+	// the config next to the spec wins over the one at the git root
+	file, err := findProjectMockConfig([]string{filepath.Join(root, "api", "users", "users.yaml") + ":/users"})
+	require.NoError(t, err)
+	assert.Equal(specConfig, file)
+
+	file, err = findProjectMockConfig([]string{filepath.Join(root, "api", "todos.yaml")})
+	require.NoError(t, err)
+	assert.Equal(rootConfig, file)
+
+	// the project config is merged over the global mock section
+	require.NoError(t, viper.MergeConfigMap(map[string]interface{}{
+		"mock": map[string]interface{}{"generation": map[string]interface{}{"default_max_items": 7, "null_probability": 0.2}},
+	}))
+	require.NoError(t, mergeProjectMockConfig([]string{filepath.Join(root, "api", "todos.yaml")}))
+	assert.Equal([]string{rootConfig}, mockConfigSources)
+
+	cmd := &cobra.Command{}
+	addMockingConfigFlags(cmd)
+	config, err := loadMockingConfig(cmd, filepath.Join(t.TempDir(), "openapi-mock.yaml"))
+	require.NoError(t, err)
+	assert.Equal(2, config.Generation.DefaultMaxItems)
+	assert.Equal(0.2, config.Generation.NullProbability)
+}
+
+func TestMockConfigSources(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { mockConfigSources = nil })
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	projectConfig := filepath.Join(root, ".kusk.yaml")
+	require.NoError(t, os.WriteFile(projectConfig, []byte("mock:\n  generation:\n    default_max_items: 2\n"), 0644))
+	globalConfig := filepath.Join(t.TempDir(), ".kusk.yaml")
+	require.NoError(t, os.WriteFile(globalConfig, []byte("lint:\n  rules:\n    operation-id: off\n"), 0644))
+
+	// the global config has no mock section, so only the project config is a source once merged
+	viper.SetConfigFile(globalConfig)
+	require.NoError(t, viper.ReadInConfig())
+	recordGlobalMockConfig()
+	require.NoError(t, mergeProjectMockConfig([]string{filepath.Join(root, "todos.yaml")}))
+	assert.True(viper.IsSet("mock"))
+	assert.Equal([]string{projectConfig}, mockConfigSources)
+
+	viper.Reset()
+	mockConfigSources = nil
+	require.NoError(t, os.WriteFile(globalConfig, []byte("mock:\n  generation:\n    null_probability: 0.2\n"), 0644))
+	viper.SetConfigFile(globalConfig)
+	require.NoError(t, viper.ReadInConfig())
+	recordGlobalMockConfig()
+	require.NoError(t, mergeProjectMockConfig([]string{filepath.Join(root, "todos.yaml")}))
+	assert.Equal([]string{globalConfig, projectConfig}, mockConfigSources)
+
+	// a project config without a mock section isn't a source of the mocking config
+	viper.Reset()
+	mockConfigSources = nil
+	require.NoError(t, os.WriteFile(projectConfig, []byte("lint:\n  rules:\n    operation-id: off\n"), 0644))
+	require.NoError(t, mergeProjectMockConfig([]string{filepath.Join(root, "todos.yaml")}))
+	assert.False(viper.IsSet("mock"))
+	assert.Empty(mockConfigSources)
+}
+
+func TestWriteTempMockingConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	seed := int64(42)
+	config := mocking.DefaultConfig()
+	config.Generation.Seed = &seed
+	config.Security = &mocking.SecurityConfig{Tokens: map[string][]string{"bearerAuth": {"secret"}}}
+
+	file, err := writeTempMockingConfig(t.TempDir(), config)
+	require.NoError(t, err)
+
+	// the config file of the openapi-mock container, which only has these settings
+	var openAPIMock struct {
+		OpenAPI string `yaml:"openapi"`
+		HTTP    struct {
+			Port            int     `yaml:"port"`
+			CORSEnabled     bool    `yaml:"cors_enabled"`
+			ResponseTimeout float64 `yaml:"response_timeout"`
+		} `yaml:"http"`
+		Application struct {
+			Debug     bool   `yaml:"debug"`
+			LogFormat string `yaml:"log_format"`
+			LogLevel  string `yaml:"log_level"`
+		} `yaml:"application"`
+		Generation struct {
+			DefaultMinFloat float64 `yaml:"default_min_float"`
+			DefaultMaxFloat float64 `yaml:"default_max_float"`
+			DefaultMinInt   int64   `yaml:"default_min_int"`
+			DefaultMaxInt   int64   `yaml:"default_max_int"`
+			NullProbability float64 `yaml:"null_probability"`
+			SuppressErrors  bool    `yaml:"suppress_errors"`
+			UseExamples     string  `yaml:"use_examples"`
+		} `yaml:"generation"`
+	}
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	require.NoError(t, decoder.Decode(&openAPIMock))
+	assert.Equal(config.Application.ResponseTimeout, openAPIMock.HTTP.ResponseTimeout)
+	assert.Equal(config.Application.LogLevel, openAPIMock.Application.LogLevel)
+	assert.Equal(config.Generation.UseExamples, openAPIMock.Generation.UseExamples)
+	assert.Equal(config.Generation.DefaultMaxInt, openAPIMock.Generation.DefaultMaxInt)
+}
+
+func TestRedactMockingConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config := mocking.DefaultConfig()
+	config.Security = &mocking.SecurityConfig{Tokens: map[string][]string{
+		"bearerAuth": {"secret-token"},
+		"basicAuth":  {"user:password", "admin:admin"},
+	}}
+
+	redacted := redactMockingConfig(config)
+	assert.Equal(map[string][]string{
+		"bearerAuth": {redactedCredential},
+		"basicAuth":  {redactedCredential, redactedCredential},
+	}, redacted.Security.Tokens)
+	assert.Equal([]string{"secret-token"}, config.Security.Tokens["bearerAuth"], "the config in effect keeps its credentials")
+	assert.Nil(redactMockingConfig(mocking.DefaultConfig()).Security)
+}
@@ -0,0 +1,293 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+type fakeBackend struct {
+	reloaded  [][]mocking.Spec
+	reloadErr error
+	stopped   bool
+
+	logCh chan mocking.AccessLogEntry
+	errCh chan error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		logCh: make(chan mocking.AccessLogEntry),
+		errCh: make(chan error),
+	}
+}
+
+func (f *fakeBackend) Start(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeBackend) Stop(ctx context.Context) error {
+	f.stopped = true
+	return nil
+}
+
+func (f *fakeBackend) Reload(ctx context.Context, specs []mocking.Spec) error {
+	if f.reloadErr != nil {
+		return f.reloadErr
+	}
+
+	f.reloaded = append(f.reloaded, specs)
+	return nil
+}
+
+func (f *fakeBackend) Logs() <-chan mocking.AccessLogEntry {
+	return f.logCh
+}
+
+func (f *fakeBackend) Errors() <-chan error {
+	return f.errCh
+}
+
+func TestRunMockBackend(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	backend := newFakeBackend()
+	specs := []mocking.Spec{{Name: "todos", API: &openapi3.T{OpenAPI: "3.0.0"}}}
+	reloadCh := make(chan struct{})
+	sigs := make(chan os.Signal)
+
+	var logged []mocking.AccessLogEntry
+	done := make(chan error)
+	go func() {
+		done <- runMockBackend(context.Background(), backend, func() ([]mocking.Spec, error) {
+			return specs, nil
+		}, func(entry mocking.AccessLogEntry) {
+			logged = append(logged, entry)
+		}, reloadCh, sigs)
+	}()
+
+	entry := mocking.AccessLogEntry{Method: "GET", Path: "/", StatusCode: "200"}
+	backend.logCh <- entry
+	backend.errCh <- errors.New("a warning that doesn't stop the server")
+	reloadCh <- struct{}{}
+	sigs <- syscall.SIGINT
+
+	assert.NoError(<-done)
+	assert.Equal([][]mocking.Spec{specs}, backend.reloaded)
+	assert.True(backend.stopped)
+	assert.Equal([]mocking.AccessLogEntry{entry}, logged)
+}
+
+func TestRunMockBackendInvalidReload(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	backend := newFakeBackend()
+	backend.reloadErr = errors.New("scenario operation not found")
+	reloadCh := make(chan struct{})
+	sigs := make(chan os.Signal)
+
+	loads := 0
+	done := make(chan error)
+	go func() {
+		done <- runMockBackend(context.Background(), backend, func() ([]mocking.Spec, error) {
+			loads++
+			if loads == 1 {
+				return nil, errors.New("invalid spec")
+			}
+			return []mocking.Spec{{Name: "todos", API: &openapi3.T{OpenAPI: "3.0.0"}}}, nil
+		}, nil, reloadCh, sigs)
+	}()
+
+	// neither an invalid spec nor a failed reload stop the previous spec from being served
+	reloadCh <- struct{}{}
+	reloadCh <- struct{}{}
+	sigs <- syscall.SIGINT
+
+	assert.NoError(<-done)
+	assert.Equal(2, loads)
+	assert.Empty(backend.reloaded)
+	assert.True(backend.stopped)
+}
+
+func TestRunMockBackendExited(t *testing.T) {
+	t.Parallel()
+
+	backend := newFakeBackend()
+
+	done := make(chan error)
+	go func() {
+		done <- runMockBackend(context.Background(), backend, nil, nil, nil, nil)
+	}()
+
+	backend.errCh <- fmt.Errorf("%w with status code 1", mocking.ErrBackendExited)
+
+	assert.ErrorIs(t, <-done, mocking.ErrBackendExited)
+}
+
+func TestParseMockInput(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input    string
+		expected mockInput
+		name     string
+	}{
+		{input: "users.yaml", expected: mockInput{path: "users.yaml"}, name: "users"},
+		{input: "specs/users.yaml:/users", expected: mockInput{path: "specs/users.yaml", prefix: "/users"}, name: "users"},
+		{input: "orders.json:/api/orders/", expected: mockInput{path: "orders.json", prefix: "/api/orders"}, name: "orders"},
+		{input: "https://example.com/orders.yaml", expected: mockInput{path: "https://example.com/orders.yaml"}, name: "orders"},
+		{input: "http://localhost:8000/users.yaml:/users", expected: mockInput{path: "http://localhost:8000/users.yaml", prefix: "/users"}, name: "users"},
+	}
+
+	for _, tc := range testCases {
+		input := parseMockInput(tc.input)
+		assert.Equal(t, tc.expected, input, tc.input)
+		assert.Equal(t, tc.name, input.name(), tc.input)
+	}
+}
+
+func TestNewAccessLogWriterJSON(t *testing.T) {
+	t.Parallel()
+
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	writeLog, closeLog, err := newAccessLogWriter(mockLogFormatJSON, logFile)
+	require.NoError(t, err)
+
+	writeLog(mocking.AccessLogEntry{
+		TimeStamp:  time.Date(2022, time.October, 17, 10, 0, 0, 0, time.UTC),
+		Method:     "GET",
+		Path:       "/todos/1",
+		StatusCode: "200",
+		Latency:    1500 * time.Microsecond,
+		Operation:  "getTodo",
+		Source:     mocking.SourceSchema,
+	})
+	writeLog(mocking.AccessLogEntry{
+		TimeStamp:  time.Date(2022, time.October, 17, 10, 0, 1, 0, time.UTC),
+		Method:     "GET",
+		Path:       "/todos/2",
+		StatusCode: "-",
+		Error:      errors.New("connection reset by scenario"),
+	})
+	require.NoError(t, closeLog())
+
+	b, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"timestamp": "2022-10-17T10:00:00Z", "method": "GET", "path": "/todos/1", "operationId": "getTodo", "status": 200, "latencyMs": 1.5, "source": "schema"}`, lines[0])
+	assert.JSONEq(t, `{"timestamp": "2022-10-17T10:00:01Z", "method": "GET", "path": "/todos/2", "latencyMs": 0, "error": "connection reset by scenario"}`, lines[1])
+
+	_, _, err = newAccessLogWriter(mockLogFormatText, logFile)
+	assert.Error(t, err)
+}
+
+func TestNewAccessLogWriterJSONKeepsStdoutOpen(t *testing.T) {
+	t.Parallel()
+
+	_, closeLog, err := newAccessLogWriter(mockLogFormatJSON, "")
+	require.NoError(t, err)
+	require.NoError(t, closeLog())
+
+	_, err = os.Stdout.Stat()
+	assert.NoError(t, err)
+}
+
+func TestLoadMockSpecReturnsDocument(t *testing.T) {
+	t.Parallel()
+
+	root := `openapi: 3.0.0
+info:
+  title: todos
+  version: 0.0.1
+paths:
+  /todos:
+    get:
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: 'schemas.yaml#/Todo'
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/schemas.yaml" {
+			fmt.Fprint(w, "Todo:\n  type: object\n")
+			return
+		}
+		fmt.Fprint(w, root)
+	}))
+	defer server.Close()
+
+	_, document, err := loadMockSpec(server.URL + "/todos.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, root, string(document))
+}
+
+func TestLoadMockSpecReadsLatestVersion(t *testing.T) {
+	t.Parallel()
+
+	valid := `openapi: 3.0.0
+info:
+  title: todos
+  version: 0.0.1
+paths:
+  /todos:
+    get:
+      responses:
+        '200':
+          description: ok
+`
+	apiSpecPath := filepath.Join(t.TempDir(), "todos.yaml")
+	require.NoError(t, os.WriteFile(apiSpecPath, []byte(valid), 0644))
+
+	_, _, err := loadMockSpec(apiSpecPath)
+	require.NoError(t, err)
+
+	// reloading must not serve a cached version of the spec
+	require.NoError(t, os.WriteFile(apiSpecPath, []byte(strings.Replace(valid, "3.0.0", "", 1)), 0644))
+	_, _, err = loadMockSpec(apiSpecPath)
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+// Package apidiff compares two versions of an OpenAPI spec served by kusk gateway: the routes they expose,
+// their x-kusk options and the upstreams they route to.
+package apidiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const kuskExtensionKey = "x-kusk"
+
+// kinds of changes
+const (
+	PathAdded        = "path added"
+	PathRemoved      = "path removed"
+	OperationAdded   = "operation added"
+	OperationRemoved = "operation removed"
+	OptionChanged    = "x-kusk option changed"
+	UpstreamChanged  = "upstream changed"
+	FleetChanged     = "envoyfleet changed"
+)
+
+// Change is a difference between two versions of a spec
+type Change struct {
+	Kind string `json:"kind"`
+	// Location is the path, the operation as "METHOD /path" or the x-kusk option
+	// as "paths./pets.get.x-kusk.qos.retries" that changed
+	Location string `json:"location"`
+	// From and To are the previous and new values of options, nil when the option wasn't set
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case PathAdded, OperationAdded:
+		return "+ " + c.Location
+	case PathRemoved, OperationRemoved:
+		return "- " + c.Location
+	}
+
+	return fmt.Sprintf("~ %s: %s → %s", c.Location, formatValue(c.From), formatValue(c.To))
+}
+
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "(unset)"
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(b)
+}
+
+// Compare returns the changes from the base spec to the revision, grouped by kind in the order of the spec
+func Compare(base, revision *openapi3.T) ([]Change, error) {
+	var changes []Change
+
+	options, err := compareOptions(kuskExtensionKey, base.ExtensionProps, revision.ExtensionProps)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, options...)
+
+	for _, path := range sortedPaths(base.Paths, revision.Paths) {
+		basePath, revisionPath := base.Paths[path], revision.Paths[path]
+		switch {
+		case revisionPath == nil:
+			changes = append(changes, Change{Kind: PathRemoved, Location: pathWithMethods(path, basePath)})
+			continue
+		case basePath == nil:
+			changes = append(changes, Change{Kind: PathAdded, Location: pathWithMethods(path, revisionPath)})
+			continue
+		}
+
+		location := "paths." + path
+		options, err := compareOptions(location+"."+kuskExtensionKey, basePath.ExtensionProps, revisionPath.ExtensionProps)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, options...)
+
+		baseOperations, revisionOperations := basePath.Operations(), revisionPath.Operations()
+		for _, method := range sortedMethods(baseOperations, revisionOperations) {
+			baseOperation, revisionOperation := baseOperations[method], revisionOperations[method]
+			switch {
+			case revisionOperation == nil:
+				changes = append(changes, Change{Kind: OperationRemoved, Location: method + " " + path})
+				continue
+			case baseOperation == nil:
+				changes = append(changes, Change{Kind: OperationAdded, Location: method + " " + path})
+				continue
+			}
+
+			options, err := compareOptions(location+"."+strings.ToLower(method)+"."+kuskExtensionKey, baseOperation.ExtensionProps, revisionOperation.ExtensionProps)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, options...)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return kindOrder[changes[i].Kind] < kindOrder[changes[j].Kind]
+	})
+
+	return changes, nil
+}
+
+var kindOrder = map[string]int{
+	FleetChanged:     0,
+	PathAdded:        1,
+	PathRemoved:      2,
+	OperationAdded:   3,
+	OperationRemoved: 4,
+	UpstreamChanged:  5,
+	OptionChanged:    6,
+}
+
+// compareOptions compares the x-kusk options set at the location, leaving out the ones
+// inherited from the parent locations so a change is only reported where it's made
+func compareOptions(location string, base, revision openapi3.ExtensionProps) ([]Change, error) {
+	baseOptions, err := flattenExtension(location, base)
+	if err != nil {
+		return nil, err
+	}
+	revisionOptions, err := flattenExtension(location, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(baseOptions)+len(revisionOptions))
+	for key := range baseOptions {
+		keys = append(keys, key)
+	}
+	for key := range revisionOptions {
+		if _, ok := baseOptions[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []Change
+	for _, key := range keys {
+		from, to := baseOptions[key], revisionOptions[key]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+
+		kind := OptionChanged
+		if key == "upstream" || key == "redirect" || strings.HasPrefix(key, "upstream.") || strings.HasPrefix(key, "redirect.") {
+			kind = UpstreamChanged
+		}
+		changes = append(changes, Change{Kind: kind, Location: location + "." + key, From: from, To: to})
+	}
+
+	return changes, nil
+}
+
+// flattenExtension returns the x-kusk options by their dotted path, e.g. qos.retries
+func flattenExtension(location string, props openapi3.ExtensionProps) (map[string]interface{}, error) {
+	extension, ok := props.Extensions[kuskExtensionKey]
+	if !ok {
+		return nil, nil
+	}
+
+	b, ok := extension.(json.RawMessage)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(extension); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", location, err)
+		}
+	}
+
+	var options interface{}
+	if err := json.Unmarshal(b, &options); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", location, err)
+	}
+
+	flat := map[string]interface{}{}
+	flatten("", options, flat)
+
+	return flat, nil
+}
+
+// flatten adds the leaves of the value to flat, lists are kept whole
+func flatten(prefix string, value interface{}, flat map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) == 0 {
+		if prefix != "" {
+			flat[prefix] = value
+		}
+		return
+	}
+
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, value, flat)
+	}
+}
+
+func pathWithMethods(path string, pathItem *openapi3.PathItem) string {
+	methods := make([]string, 0, len(pathItem.Operations()))
+	for method := range pathItem.Operations() {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return fmt.Sprintf("%s (%s)", path, strings.Join(methods, ", "))
+}
+
+func sortedPaths(base, revision openapi3.Paths) []string {
+	paths := make([]string, 0, len(base)+len(revision))
+	for path := range base {
+		paths = append(paths, path)
+	}
+	for path := range revision {
+		if _, ok := base[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func sortedMethods(base, revision map[string]*openapi3.Operation) []string {
+	methods := make([]string, 0, len(base)+len(revision))
+	for method := range base {
+		methods = append(methods, method)
+	}
+	for method := range revision {
+		if _, ok := base[method]; !ok {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+
+	return methods
+}
@@ -0,0 +1,76 @@
+package apidiff
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestSpec(t *testing.T, path string) *openapi3.T {
+	t.Helper()
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile(path)
+	require.NoError(t, err)
+
+	return apiSpec
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	changes, err := Compare(loadTestSpec(t, "testdata/base.yaml"), loadTestSpec(t, "testdata/revision.yaml"))
+	require.NoError(t, err)
+
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	assert.Equal([]string{
+		"+ /owners (GET)",
+		"- DELETE /pets/{id}",
+		"~ x-kusk.upstream.service.port: 80 → 8080",
+		"~ x-kusk.qos.retries: 3 → 5",
+		"~ paths./pets.post.x-kusk.qos.request_timeout: 5 → (unset)",
+		`~ paths./pets/{id}.x-kusk.cors.origins: (unset) → ["*"]`,
+	}, lines)
+
+	changes, err = Compare(loadTestSpec(t, "testdata/base.yaml"), loadTestSpec(t, "testdata/base.yaml"))
+	require.NoError(t, err)
+	assert.Empty(changes)
+}
+
+func TestBreaking(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	changes := Breaking(loadTestSpec(t, "testdata/breaking/base.yaml"), loadTestSpec(t, "testdata/breaking/revision.yaml"))
+
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	assert.Equal([]string{
+		"GET /pets parameters.header.X-Tenant: new required parameter",
+		"GET /pets parameters.query.limit: parameter is now required",
+		"GET /pets parameters.query.limit: maximum lowered to 50",
+		`GET /pets parameters.query.status: enum values ["sold"] are no longer accepted`,
+		"GET /pets responses.200.application/json[].tag: field removed",
+		"POST /pets requestBody: request body is now required",
+		"POST /pets requestBody.application/json.tag: new required field",
+		"POST /pets responses.200: status 200 is no longer returned, the operation returns 201",
+		"DELETE /pets/{id}: operation removed",
+		"GET /pets/{id} parameters.path.id: type changed from string to integer",
+		"GET /pets/{id} responses.200.application/json.tag: field removed",
+		"GET /pets/{id}/owner responses.200.application/json.contact.oneOf[2]: new variant may be returned",
+		"GET /pets/{id}/owner responses.200.application/json.email: field is no longer required and may be missing",
+		"GET /pets/{id}/owner responses.200.application/json.nickname: null may be returned now",
+		`GET /pets/{id}/owner responses.200.application/json.status: enum values ["banned"] may be returned now`,
+		"PUT /pets/{id}/owner requestBody.application/json.contact.oneOf[1]: variant no longer accepted",
+	}, lines)
+	assert.Equal(RequiredAdded, changes[0].Kind)
+
+	assert.Empty(Breaking(loadTestSpec(t, "testdata/breaking/base.yaml"), loadTestSpec(t, "testdata/breaking/base.yaml")))
+}
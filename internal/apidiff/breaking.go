@@ -0,0 +1,567 @@
+package apidiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// kinds of breaking changes, along with OperationRemoved
+const (
+	RequiredAdded        = "new required parameter"
+	TypeNarrowed         = "type narrowed"
+	ResponseFieldRemoved = "response field removed"
+	// ResponseWidened is a response returning values clients of the base spec don't expect,
+	// such as null, new enum values or fields that are no longer always returned
+	ResponseWidened   = "response widened"
+	StatusCodeChanged = "status code changed"
+)
+
+// maxSchemaDepth stops recursive schemas from being compared infinitely
+const maxSchemaDepth = 10
+
+// BreakingChange is a change of the spec that breaks clients of the previous version
+type BreakingChange struct {
+	Kind string `json:"kind"`
+	// Operation is the operation broken as "METHOD /path"
+	Operation string `json:"operation"`
+	// Location is the parameter, request body or response field of the operation that changed, if any
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (c BreakingChange) String() string {
+	if c.Location == "" {
+		return fmt.Sprintf("%s: %s", c.Operation, c.Message)
+	}
+
+	return fmt.Sprintf("%s %s: %s", c.Operation, c.Location, c.Message)
+}
+
+// Breaking returns the changes from the base spec to the revision that break clients of the base spec: removed operations,
+// new required parameters and request fields, narrowed types, removed or widened response fields and removed status codes.
+// Paths are matched regardless of the names of their path parameters, so /pets/{id} renamed to /pets/{petId} is the same path.
+func Breaking(base, revision *openapi3.T) []BreakingChange {
+	revisionPaths := pathsByTemplate(revision.Paths)
+
+	var changes []BreakingChange
+	for _, path := range sortedPaths(base.Paths, nil) {
+		basePath := base.Paths[path]
+		if basePath == nil {
+			continue
+		}
+
+		var revisionOperations map[string]*openapi3.Operation
+		var renamed map[string]string
+		revisionPath := revision.Paths[path]
+		if revisionPath == nil {
+			if revisionTemplate, ok := revisionPaths[pathTemplate(path)]; ok {
+				revisionPath, renamed = revision.Paths[revisionTemplate], renamedPathParams(path, revisionTemplate)
+			}
+		}
+		if revisionPath != nil {
+			revisionOperations = revisionPath.Operations()
+		}
+
+		baseOperations := basePath.Operations()
+		for _, method := range sortedMethods(baseOperations, nil) {
+			c := operationComparison{operation: method + " " + path}
+
+			revisionOperation := revisionOperations[method]
+			if revisionOperation == nil {
+				c.add(OperationRemoved, "", "operation removed")
+				changes = append(changes, c.changes...)
+				continue
+			}
+
+			c.compareParameters(parameters(basePath, baseOperations[method], nil), parameters(revisionPath, revisionOperation, renamed))
+			c.compareRequestBody(baseOperations[method].RequestBody, revisionOperation.RequestBody)
+			c.compareResponses(baseOperations[method].Responses, revisionOperation.Responses)
+			changes = append(changes, c.changes...)
+		}
+	}
+
+	return changes
+}
+
+// pathTemplate returns the path without the names of its path parameters, e.g. /pets/{} for /pets/{id}
+func pathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "{}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// pathsByTemplate indexes the paths by their template
+func pathsByTemplate(paths openapi3.Paths) map[string]string {
+	templates := make(map[string]string, len(paths))
+	for _, path := range sortedPaths(paths, nil) {
+		if _, ok := templates[pathTemplate(path)]; !ok {
+			templates[pathTemplate(path)] = path
+		}
+	}
+
+	return templates
+}
+
+// renamedPathParams maps the names of the path parameters of the revision path to their names in the base path
+func renamedPathParams(base, revision string) map[string]string {
+	renamed := map[string]string{}
+	baseSegments, revisionSegments := strings.Split(base, "/"), strings.Split(revision, "/")
+	for i, segment := range revisionSegments {
+		if segment != baseSegments[i] {
+			renamed[strings.Trim(segment, "{}")] = strings.Trim(baseSegments[i], "{}")
+		}
+	}
+
+	return renamed
+}
+
+// operationComparison collects the breaking changes of an operation
+type operationComparison struct {
+	operation string
+	changes   []BreakingChange
+}
+
+func (c *operationComparison) add(kind, location, format string, args ...interface{}) {
+	c.changes = append(c.changes, BreakingChange{
+		Kind:      kind,
+		Operation: c.operation,
+		Location:  location,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// parameters returns the parameters of the operation by location and name, including the ones of its path.
+// Path parameters are named as in renamed when they're renamed from the base spec.
+func parameters(pathItem *openapi3.PathItem, operation *openapi3.Operation, renamed map[string]string) map[string]*openapi3.Parameter {
+	params := map[string]*openapi3.Parameter{}
+	for _, refs := range []openapi3.Parameters{pathItem.Parameters, operation.Parameters} {
+		for _, ref := range refs {
+			if ref == nil || ref.Value == nil {
+				continue
+			}
+
+			name := ref.Value.Name
+			if baseName, ok := renamed[name]; ok && ref.Value.In == openapi3.ParameterInPath {
+				name = baseName
+			}
+			params[ref.Value.In+"."+name] = ref.Value
+		}
+	}
+
+	return params
+}
+
+func (c *operationComparison) compareParameters(base, revision map[string]*openapi3.Parameter) {
+	names := make([]string, 0, len(revision))
+	for name := range revision {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		location := "parameters." + name
+		baseParam, revisionParam := base[name], revision[name]
+		switch {
+		case baseParam == nil && revisionParam.Required:
+			c.add(RequiredAdded, location, "new required parameter")
+			continue
+		case baseParam == nil:
+			continue
+		case !baseParam.Required && revisionParam.Required:
+			c.add(RequiredAdded, location, "parameter is now required")
+		}
+
+		if baseParam.Schema != nil && revisionParam.Schema != nil {
+			c.compareRequestSchema(location, baseParam.Schema.Value, revisionParam.Schema.Value, 0)
+		}
+	}
+}
+
+func (c *operationComparison) compareRequestBody(base, revision *openapi3.RequestBodyRef) {
+	if revision == nil || revision.Value == nil {
+		return
+	}
+
+	if base == nil || base.Value == nil {
+		if revision.Value.Required {
+			c.add(RequiredAdded, "requestBody", "request body is now required")
+		}
+		return
+	}
+
+	if !base.Value.Required && revision.Value.Required {
+		c.add(RequiredAdded, "requestBody", "request body is now required")
+	}
+
+	for _, contentType := range sortedContentTypes(base.Value.Content) {
+		location := "requestBody." + contentType
+		revisionMedia := revision.Value.Content.Get(contentType)
+		if revisionMedia == nil {
+			c.add(TypeNarrowed, location, "content type no longer accepted")
+			continue
+		}
+
+		if baseMedia := base.Value.Content.Get(contentType); baseMedia.Schema != nil && revisionMedia.Schema != nil {
+			c.compareRequestSchema(location, baseMedia.Schema.Value, revisionMedia.Schema.Value, 0)
+		}
+	}
+}
+
+// compareRequestSchema reports the values clients send that the revision no longer accepts
+func (c *operationComparison) compareRequestSchema(location string, base, revision *openapi3.Schema, depth int) {
+	if base == nil || revision == nil || depth > maxSchemaDepth {
+		return
+	}
+	base, revision = flattenAllOf(base, 0), flattenAllOf(revision, 0)
+
+	// integers are still accepted where numbers are
+	widened := base.Type == openapi3.TypeInteger && revision.Type == openapi3.TypeNumber
+	switch {
+	case base.Type != revision.Type && revision.Type != "" && !widened:
+		c.add(TypeNarrowed, location, "type changed from %s to %s", typeName(base), typeName(revision))
+		return
+	case base.Format != revision.Format && revision.Format != "" && !widened:
+		c.add(TypeNarrowed, location, "format changed from %s to %s", typeName(base), typeName(revision))
+	}
+
+	if len(base.Enum) == 0 && len(revision.Enum) > 0 {
+		c.add(TypeNarrowed, location, "only %s are accepted now", formatValue(revision.Enum))
+	} else if removed := newEnumValues(revision.Enum, base.Enum); len(revision.Enum) > 0 && len(removed) > 0 {
+		c.add(TypeNarrowed, location, "enum values %s are no longer accepted", formatValue(removed))
+	}
+
+	if narrowedBound(base.Min, revision.Min, func(b, r float64) bool { return r > b }) {
+		c.add(TypeNarrowed, location, "minimum raised to %v", *revision.Min)
+	}
+	if narrowedBound(base.Max, revision.Max, func(b, r float64) bool { return r < b }) {
+		c.add(TypeNarrowed, location, "maximum lowered to %v", *revision.Max)
+	}
+	if revision.MinLength > base.MinLength {
+		c.add(TypeNarrowed, location, "minLength raised to %d", revision.MinLength)
+	}
+	if revision.MaxLength != nil && (base.MaxLength == nil || *revision.MaxLength < *base.MaxLength) {
+		c.add(TypeNarrowed, location, "maxLength lowered to %d", *revision.MaxLength)
+	}
+	if revision.Pattern != "" && revision.Pattern != base.Pattern {
+		c.add(TypeNarrowed, location, "pattern changed to %s", revision.Pattern)
+	}
+	if base.Nullable && !revision.Nullable {
+		c.add(TypeNarrowed, location, "null is no longer accepted")
+	}
+
+	for _, name := range revision.Required {
+		if !contains(base.Required, name) {
+			c.add(RequiredAdded, location+"."+name, "new required field")
+		}
+	}
+
+	for _, name := range sortedProperties(base.Properties) {
+		if revisionProperty, ok := revision.Properties[name]; ok && base.Properties[name] != nil {
+			c.compareRequestSchema(location+"."+name, base.Properties[name].Value, revisionProperty.Value, depth+1)
+		}
+	}
+
+	if base.Items != nil && revision.Items != nil {
+		c.compareRequestSchema(location+"[]", base.Items.Value, revision.Items.Value, depth+1)
+	}
+
+	// clients may send any variant of the base, so each one must still be accepted
+	for _, variants := range compositions(base, revision) {
+		pairs, removed, _ := matchVariants(variants.base, variants.revision)
+		for _, i := range removed {
+			c.add(TypeNarrowed, fmt.Sprintf("%s.%s[%d]", location, variants.keyword, i), "variant no longer accepted")
+		}
+		for _, pair := range pairs {
+			c.compareRequestSchema(fmt.Sprintf("%s.%s[%d]", location, variants.keyword, pair.index), pair.base, pair.revision, depth+1)
+		}
+	}
+}
+
+func (c *operationComparison) compareResponses(base, revision openapi3.Responses) {
+	for _, status := range sortedResponses(base) {
+		location := "responses." + status
+		revisionResponse := revision[status]
+		if revisionResponse == nil {
+			c.add(StatusCodeChanged, location, "status %s is no longer returned, the operation returns %s", status, strings.Join(sortedResponses(revision), ", "))
+			continue
+		}
+
+		baseResponse := base[status].Value
+		if baseResponse == nil || revisionResponse.Value == nil {
+			continue
+		}
+
+		for _, contentType := range sortedContentTypes(baseResponse.Content) {
+			revisionMedia := revisionResponse.Value.Content.Get(contentType)
+			baseMedia := baseResponse.Content.Get(contentType)
+			if revisionMedia == nil || baseMedia.Schema == nil || revisionMedia.Schema == nil {
+				continue
+			}
+
+			c.compareResponseSchema(location+"."+contentType, baseMedia.Schema.Value, revisionMedia.Schema.Value, 0)
+		}
+	}
+}
+
+// compareResponseSchema reports the values clients read that the revision no longer returns
+// and the values the revision returns that clients don't expect
+func (c *operationComparison) compareResponseSchema(location string, base, revision *openapi3.Schema, depth int) {
+	if base == nil || revision == nil || depth > maxSchemaDepth {
+		return
+	}
+	base, revision = flattenAllOf(base, 0), flattenAllOf(revision, 0)
+
+	if base.Type != revision.Type && base.Type != "" {
+		c.add(TypeNarrowed, location, "type changed from %s to %s", typeName(base), typeName(revision))
+		return
+	}
+
+	if !base.Nullable && revision.Nullable {
+		c.add(ResponseWidened, location, "null may be returned now")
+	}
+	if len(base.Enum) > 0 && len(revision.Enum) == 0 {
+		c.add(ResponseWidened, location, "values other than %s may be returned now", formatValue(base.Enum))
+	} else if added := newEnumValues(base.Enum, revision.Enum); len(base.Enum) > 0 && len(added) > 0 {
+		c.add(ResponseWidened, location, "enum values %s may be returned now", formatValue(added))
+	}
+
+	for _, name := range sortedProperties(base.Properties) {
+		revisionProperty, ok := revision.Properties[name]
+		if !ok {
+			c.add(ResponseFieldRemoved, location+"."+name, "field removed")
+			continue
+		}
+
+		if contains(base.Required, name) && !contains(revision.Required, name) {
+			c.add(ResponseWidened, location+"."+name, "field is no longer required and may be missing")
+		}
+
+		if base.Properties[name] != nil {
+			c.compareResponseSchema(location+"."+name, base.Properties[name].Value, revisionProperty.Value, depth+1)
+		}
+	}
+
+	if base.Items != nil && revision.Items != nil {
+		c.compareResponseSchema(location+"[]", base.Items.Value, revision.Items.Value, depth+1)
+	}
+
+	// clients only handle the variants of the base, so new variants are unexpected
+	for _, variants := range compositions(base, revision) {
+		pairs, _, added := matchVariants(variants.base, variants.revision)
+		for _, j := range added {
+			c.add(ResponseWidened, fmt.Sprintf("%s.%s[%d]", location, variants.keyword, j), "new variant may be returned")
+		}
+		for _, pair := range pairs {
+			c.compareResponseSchema(fmt.Sprintf("%s.%s[%d]", location, variants.keyword, pair.index), pair.base, pair.revision, depth+1)
+		}
+	}
+}
+
+// flattenAllOf merges the schemas of allOf into the schema, so their type, properties and
+// required fields are compared as if the schema declared them itself
+func flattenAllOf(schema *openapi3.Schema, depth int) *openapi3.Schema {
+	if len(schema.AllOf) == 0 || depth > maxSchemaDepth {
+		return schema
+	}
+
+	flattened := *schema
+	flattened.AllOf = nil
+	flattened.Properties = openapi3.Schemas{}
+	for name, property := range schema.Properties {
+		flattened.Properties[name] = property
+	}
+	flattened.Required = append([]string(nil), schema.Required...)
+
+	for _, ref := range schema.AllOf {
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+
+		part := flattenAllOf(ref.Value, depth+1)
+		if flattened.Type == "" {
+			flattened.Type, flattened.Format = part.Type, part.Format
+		}
+		if len(flattened.Enum) == 0 {
+			flattened.Enum = part.Enum
+		}
+		if flattened.Items == nil {
+			flattened.Items = part.Items
+		}
+		for name, property := range part.Properties {
+			if _, ok := flattened.Properties[name]; !ok {
+				flattened.Properties[name] = property
+			}
+		}
+		for _, name := range part.Required {
+			if !contains(flattened.Required, name) {
+				flattened.Required = append(flattened.Required, name)
+			}
+		}
+	}
+
+	return &flattened
+}
+
+// composition is the variants of oneOf or anyOf in the base and the revision
+type composition struct {
+	keyword        string
+	base, revision openapi3.SchemaRefs
+}
+
+func compositions(base, revision *openapi3.Schema) []composition {
+	return []composition{
+		{keyword: "oneOf", base: base.OneOf, revision: revision.OneOf},
+		{keyword: "anyOf", base: base.AnyOf, revision: revision.AnyOf},
+	}
+}
+
+// variantPair is a variant of the base along with the same variant in the revision
+type variantPair struct {
+	// index is the index of the variant in the base
+	index          int
+	base, revision *openapi3.Schema
+}
+
+// matchVariants matches the variants of the base with the ones of the revision by the schema they reference,
+// and by their order otherwise. It also returns the indexes of the variants only in the base and only in the revision.
+func matchVariants(base, revision openapi3.SchemaRefs) ([]variantPair, []int, []int) {
+	matches := make([]int, len(base))
+	used := make([]bool, len(revision))
+	for i, ref := range base {
+		matches[i] = -1
+		if ref.Ref == "" {
+			continue
+		}
+		for j, revisionRef := range revision {
+			if !used[j] && revisionRef.Ref == ref.Ref {
+				matches[i], used[j] = j, true
+				break
+			}
+		}
+	}
+
+	next := 0
+	for i, ref := range base {
+		if matches[i] >= 0 || ref.Ref != "" {
+			continue
+		}
+		for next < len(revision) && (used[next] || revision[next].Ref != "") {
+			next++
+		}
+		if next < len(revision) {
+			matches[i], used[next] = next, true
+		}
+	}
+
+	var pairs []variantPair
+	var removed, added []int
+	for i, j := range matches {
+		switch {
+		case j < 0:
+			removed = append(removed, i)
+		case base[i].Value != nil && revision[j].Value != nil:
+			pairs = append(pairs, variantPair{index: i, base: base[i].Value, revision: revision[j].Value})
+		}
+	}
+	for j := range revision {
+		if !used[j] {
+			added = append(added, j)
+		}
+	}
+
+	return pairs, removed, added
+}
+
+func typeName(schema *openapi3.Schema) string {
+	name := schema.Type
+	if name == "" {
+		name = "any"
+	}
+	if schema.Format != "" {
+		name += " (" + schema.Format + ")"
+	}
+
+	return name
+}
+
+// newEnumValues returns the values of the revision that the base doesn't have, none when the revision has no enum
+func newEnumValues(base, revision []interface{}) []interface{} {
+	if len(revision) == 0 {
+		return nil
+	}
+
+	var added []interface{}
+	for _, value := range revision {
+		if !containsValue(base, value) {
+			added = append(added, value)
+		}
+	}
+
+	return added
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if formatValue(v) == formatValue(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func narrowedBound(base, revision *float64, narrower func(base, revision float64) bool) bool {
+	if revision == nil {
+		return false
+	}
+
+	return base == nil || narrower(*base, *revision)
+}
+
+func sortedResponses(responses openapi3.Responses) []string {
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	return statuses
+}
+
+func sortedContentTypes(content openapi3.Content) []string {
+	contentTypes := make([]string, 0, len(content))
+	for contentType := range content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+
+	return contentTypes
+}
+
+func sortedProperties(properties openapi3.Schemas) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
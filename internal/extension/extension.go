@@ -0,0 +1,266 @@
+// Package extension reads the x-kusk extension of OpenAPI specs the way kusk gateway does: the options
+// of each path are merged over the root options, and the options of each operation over the ones of their path.
+package extension
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// Key is the key of the extension in the spec
+const Key = "x-kusk"
+
+// Error is an option of the extension that kusk gateway would reject
+type Error struct {
+	// Location is the option rejected, e.g. paths./pets.get.x-kusk.qos.retries
+	Location string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Location + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Level is the root, a path or an operation of a spec with the options declared there
+type Level struct {
+	// Location is where the extension is declared, e.g. x-kusk or paths./pets.get.x-kusk
+	Location string
+	// Operation is the operation as "METHOD /path", empty for the root and paths
+	Operation string
+	// Declared tells whether the level declares the extension
+	Declared bool
+	// Options are the options declared at the level
+	Options options.SubOptions
+	// Effective are the options in effect at the level, merged over the ones of its parents
+	Effective options.SubOptions
+	// Hosts are only declared at the root
+	Hosts []options.Host
+
+	// keys are the top-level keys of the options declared at the level
+	keys map[string]bool
+}
+
+// Levels returns the root of the spec followed by each path and its operations, in the order of the spec.
+// Extensions that can't be decoded are returned as errors and read as if they weren't declared.
+func Levels(apiSpec *openapi3.T) ([]Level, []*Error) {
+	var errs []*Error
+
+	var root options.Options
+	rootLevel := Level{Location: Key}
+	keys, declared, err := decode(apiSpec.ExtensionProps, &root)
+	if err != nil {
+		errs = append(errs, decodeError(Key, err))
+		root, declared, keys = options.Options{}, false, nil
+	}
+	root.FillDefaults()
+	rootLevel.Declared, rootLevel.keys = declared, keys
+	rootLevel.Options, rootLevel.Effective, rootLevel.Hosts = root.SubOptions, root.SubOptions, root.Hosts
+
+	levels := []Level{rootLevel}
+	for _, path := range SortedPaths(apiSpec.Paths) {
+		pathItem := apiSpec.Paths[path]
+		location := "paths." + path
+		pathLevel, err := newLevel(location+"."+Key, "", pathItem.ExtensionProps, rootLevel.Effective)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		levels = append(levels, pathLevel)
+
+		operations := pathItem.Operations()
+		for _, method := range SortedMethods(operations) {
+			operationLocation := location + "." + strings.ToLower(method) + "." + Key
+			operationLevel, err := newLevel(operationLocation, method+" "+path, operations[method].ExtensionProps, pathLevel.Effective)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			levels = append(levels, operationLevel)
+		}
+	}
+
+	return levels, errs
+}
+
+func newLevel(location, operation string, props openapi3.ExtensionProps, parent options.SubOptions) (Level, *Error) {
+	level := Level{Location: location, Operation: operation}
+
+	var err *Error
+	keys, declared, decodeErr := decode(props, &level.Options)
+	if decodeErr != nil {
+		err = decodeError(location, decodeErr)
+		level.Options, declared, keys = options.SubOptions{}, false, nil
+	}
+	level.Declared, level.keys = declared, keys
+
+	level.Effective = level.Options
+	level.Effective.MergeInSubOptions(&parent)
+	if level.Effective.Upstream != nil {
+		level.Effective.Upstream.FillDefaults()
+	}
+
+	return level, err
+}
+
+// Validate returns the options kusk gateway would reject. The options in effect are validated at each level
+// declaring the extension, and errors are reported where the options are declared rather than where they're inherited.
+func Validate(apiSpec *openapi3.T) []*Error {
+	levels, errs := Levels(apiSpec)
+	for _, level := range levels {
+		if !level.Declared {
+			continue
+		}
+
+		for i, host := range level.Hosts {
+			if err := host.Validate(); err != nil {
+				errs = append(errs, &Error{Location: fmt.Sprintf("%s.hosts.%d", level.Location, i), Err: err})
+			}
+		}
+
+		errs = append(errs, validateOptions(level)...)
+	}
+
+	return errs
+}
+
+// validateOptions validates the options in effect at the level, leaving out the errors of the options it inherits
+func validateOptions(level Level) []*Error {
+	var errs []*Error
+	effective := level.Effective
+
+	// kusk gateway dereferences validation.request.enabled and mocking.enabled when both validation and mocking
+	// are set, so they're checked first and validation is left out of the rest of the validation when they're unset
+	if effective.Validation != nil && effective.Mocking != nil &&
+		(effective.Validation.Request == nil || effective.Validation.Request.Enabled == nil || effective.Mocking.Enabled == nil) {
+		if key := declaredKey(level, "mocking", "validation"); key != "" {
+			errs = append(errs, &Error{
+				Location: level.Location + "." + key,
+				Err:      errors.New("validation.request.enabled and mocking.enabled must both be set when validation and mocking are combined"),
+			})
+		}
+		effective.Validation = nil
+	}
+
+	for _, err := range validationErrors(level.Location, effective.Validate()) {
+		// options inherited from the parents are reported by their parents
+		key := strings.SplitN(strings.TrimPrefix(err.Location, level.Location+"."), ".", 2)[0]
+		if err.Location != level.Location && !level.keys[key] {
+			continue
+		}
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// declaredKey returns the first of the keys declared at the level, the combinations of options
+// declared at different levels are reported by the last level declaring one of them
+func declaredKey(level Level, keys ...string) string {
+	for _, key := range keys {
+		if level.keys[key] {
+			return key
+		}
+	}
+
+	return ""
+}
+
+// decode decodes the extension into target as kusk gateway does, rejecting unknown options.
+// It returns the top-level keys of the extension and whether it is declared.
+func decode(props openapi3.ExtensionProps, target interface{}) (map[string]bool, bool, error) {
+	extension, ok := props.Extensions[Key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	b, ok := extension.(json.RawMessage)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(extension); err != nil {
+			return nil, true, err
+		}
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, true, errors.New("should be an object")
+	}
+	keys := make(map[string]bool, len(object))
+	for key := range object {
+		keys[key] = true
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return keys, true, err
+	}
+
+	return keys, true, nil
+}
+
+func decodeError(location string, err error) *Error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &Error{Location: location + "." + typeErr.Field, Err: fmt.Errorf("invalid %s, expected %s", typeErr.Value, typeErr.Type)}
+	}
+
+	return &Error{Location: location, Err: errors.New(strings.TrimPrefix(err.Error(), "json: "))}
+}
+
+// validationErrors flattens the errors of the validation of the options by their location
+func validationErrors(location string, err error) []*Error {
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs v.Errors
+	if !errors.As(err, &fieldErrs) {
+		return []*Error{{Location: location, Err: err}}
+	}
+
+	fields := make([]string, 0, len(fieldErrs))
+	for field := range fieldErrs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var errs []*Error
+	for _, field := range fields {
+		errs = append(errs, validationErrors(location+"."+field, fieldErrs[field])...)
+	}
+
+	return errs
+}
+
+// SortedPaths returns the paths of the spec in the order levels are returned
+func SortedPaths(paths openapi3.Paths) []string {
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// SortedMethods returns the methods of the operations of a path in the order levels are returned
+func SortedMethods(operations map[string]*openapi3.Operation) []string {
+	methods := make([]string, 0, len(operations))
+	for method := range operations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
@@ -0,0 +1,55 @@
+package extension
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile("testdata/spec.yaml")
+	require.NoError(t, err)
+
+	var errs []string
+	for _, err := range Validate(apiSpec) {
+		errs = append(errs, err.Error())
+	}
+	assert.Equal([]string{
+		"paths./owners.get.x-kusk.qos.retries: invalid string, expected uint32",
+		`paths./pets.delete.x-kusk: unknown field "retires"`,
+		"x-kusk.hosts.1: must be a valid IP address or DNS name",
+		"paths./pets.x-kusk.upstream.service.port: must be no greater than 65356",
+		"paths./pets.post.x-kusk.upstream: Host and Service are mutually exclusive",
+	}, errs)
+}
+
+func TestLevels(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile("testdata/spec.yaml")
+	require.NoError(t, err)
+
+	levels, _ := Levels(apiSpec)
+	require.Len(t, levels, 7)
+
+	root := levels[0]
+	assert.Equal("x-kusk", root.Location)
+	assert.True(root.Declared)
+	// defaults are filled like kusk gateway does
+	assert.Equal("default", root.Effective.Upstream.Service.Namespace)
+	assert.Equal(uint32(80), root.Effective.Upstream.Service.Port)
+
+	getPets := levels[5]
+	assert.Equal("paths./pets.get.x-kusk", getPets.Location)
+	assert.Equal("GET /pets", getPets.Operation)
+	assert.Nil(getPets.Options.Upstream)
+	assert.Equal(uint32(70000), getPets.Effective.Upstream.Service.Port)
+	assert.Equal(uint32(3), getPets.Effective.QoS.Retries)
+	assert.Equal([]string{"*"}, getPets.Effective.CORS.Origins)
+}
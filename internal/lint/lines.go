@@ -0,0 +1,57 @@
+package lint
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetLines sets the line of the findings from the source of the spec, YAML or JSON. Findings are given
+// the line of the deepest part of their location found in the source, e.g. of x-kusk.qos when x-kusk.qos.retries isn't set.
+func SetLines(findings []Finding, source []byte) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(source, &document); err != nil || len(document.Content) == 0 {
+		return
+	}
+
+	for i := range findings {
+		findings[i].Line = findLine(document.Content[0], findings[i].Location)
+	}
+}
+
+// findLine returns the line of the location in the node. Keys may contain dots, as paths do,
+// so the longest key the location starts with is followed.
+func findLine(node *yaml.Node, location string) int {
+	line := node.Line
+	for location != "" {
+		var next *yaml.Node
+		var rest string
+		switch node.Kind {
+		case yaml.MappingNode:
+			longest := -1
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if (location == key || strings.HasPrefix(location, key+".")) && len(key) > longest {
+					longest = len(key)
+					next, line = node.Content[i+1], node.Content[i].Line
+					rest = strings.TrimPrefix(strings.TrimPrefix(location, key), ".")
+				}
+			}
+		case yaml.SequenceNode:
+			index, remaining, _ := strings.Cut(location, ".")
+			for i, item := range node.Content {
+				if index == strconv.Itoa(i) {
+					next, line, rest = item, item.Line, remaining
+				}
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		node, location = next, rest
+	}
+
+	return line
+}
@@ -0,0 +1,160 @@
+// Package lint checks OpenAPI specs for the mistakes that keep them from being served by kusk gateway or mocked by kusk mock.
+// Each rule reports findings with its severity, which can be changed or turned off per rule.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Severity is how serious the findings of a rule are
+type Severity string
+
+// severities of rules, ordered from the most serious
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+	// Off turns a rule off
+	Off Severity = "off"
+)
+
+// Finding is something a rule found in the spec
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Location is where the finding is in the spec, e.g. paths./pets.get.x-kusk.qos.retries
+	Location string `json:"location"`
+	Message  string `json:"message"`
+	// Line is the line of the location in the spec file, 0 when it isn't known
+	Line int `json:"line,omitempty"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Location, f.Message, f.Rule)
+}
+
+// ServiceResolver returns the ports of the service upstreams route to, or why it can't be resolved
+type ServiceResolver func(namespace, name string) ([]int64, error)
+
+// Spec is a spec to lint
+type Spec struct {
+	*openapi3.T
+	// Services resolves the upstream services, they're only checked to be set when it's nil
+	Services ServiceResolver
+}
+
+// Rule checks one kind of mistake
+type Rule struct {
+	ID          string
+	Description string
+	// Severity is the severity of the findings of the rule, unless configured otherwise
+	Severity Severity
+
+	check func(spec *Spec) []Finding
+}
+
+// Rules are the rules run by Lint, in the order their findings are reported
+var Rules = []Rule{
+	{
+		ID:          "x-kusk",
+		Description: "x-kusk options at the root, path and operation level are valid once merged over their parents, as kusk gateway reads them",
+		Severity:    Error,
+		check:       checkExtension,
+	},
+	{
+		ID:          "upstream",
+		Description: "every operation routes to an upstream, a redirect or a mock, and upstream services exist in the cluster and expose the port",
+		Severity:    Error,
+		check:       checkUpstreams,
+	},
+	{
+		ID:          "operation-id",
+		Description: "every operation has a unique operationId",
+		Severity:    Warning,
+		check:       checkOperationIDs,
+	},
+	{
+		ID:          "response-examples",
+		Description: "every response with content has an example, which mocking serves instead of generated data",
+		Severity:    Warning,
+		check:       checkResponseExamples,
+	},
+}
+
+// Config is the severity of rules by rule ID, overriding their default severity
+type Config map[string]Severity
+
+// Validate reports the unknown rules and severities of the config
+func (c Config) Validate() error {
+	for id, severity := range c {
+		if _, ok := findRule(id); !ok {
+			return fmt.Errorf("unknown lint rule %q, expected one of %s", id, strings.Join(ruleIDs(), ", "))
+		}
+
+		switch severity {
+		case Error, Warning, Info, Off:
+		default:
+			return fmt.Errorf("invalid severity %q for lint rule %s, expected error, warning, info or off", severity, id)
+		}
+	}
+
+	return nil
+}
+
+// Lint runs the rules not turned off by the config over the spec
+func Lint(spec *Spec, config Config) ([]Finding, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, rule := range Rules {
+		severity := rule.Severity
+		if configured, ok := config[rule.ID]; ok {
+			severity = configured
+		}
+		if severity == Off {
+			continue
+		}
+
+		for _, finding := range rule.check(spec) {
+			finding.Rule, finding.Severity = rule.ID, severity
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, nil
+}
+
+// HasErrors tells whether any of the findings is an error
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == Error {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findRule(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+func ruleIDs() []string {
+	ids := make([]string, 0, len(Rules))
+	for _, rule := range Rules {
+		ids = append(ids, rule.ID)
+	}
+
+	return ids
+}
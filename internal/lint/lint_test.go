@@ -0,0 +1,139 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	return loadTestSpecFile(t, "testdata/spec.yaml")
+}
+
+func loadTestSpecFile(t *testing.T, path string) *openapi3.T {
+	t.Helper()
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile(path)
+	require.NoError(t, err)
+
+	return apiSpec
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	services := func(namespace, name string) ([]int64, error) {
+		if name == "pets" {
+			return []int64{80}, nil
+		}
+		return nil, fmt.Errorf("service %s/%s not found", namespace, name)
+	}
+
+	findings, err := Lint(&Spec{T: loadTestSpec(t), Services: services}, nil)
+	require.NoError(t, err)
+
+	source, err := os.ReadFile("testdata/spec.yaml")
+	require.NoError(t, err)
+	SetLines(findings, source)
+
+	lines := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		lines = append(lines, fmt.Sprintf("%d %s %s", finding.Line, finding.Severity, finding))
+	}
+	assert.Equal([]string{
+		"17 error paths./pets.get.x-kusk.qos.retries: invalid string, expected uint32 (x-kusk)",
+		"32 error paths./pets.post.x-kusk.upstream.service: service default/pets-writer can't be resolved: service default/pets-writer not found (upstream)",
+		"29 warning paths./pets.post: POST /pets has no operationId (operation-id)",
+		"11 warning paths: operationId listPets is used by GET /pets, GET /v1.0/pets (operation-id)",
+		"39 warning paths./pets.post.responses.201.content.application/json: POST /pets responds 201 application/json without an example (response-examples)",
+	}, lines)
+	assert.True(HasErrors(findings))
+}
+
+func TestLintMockingWithValidation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// a partial mocking block combined with the validation of the root doesn't crash the linter
+	findings, err := Lint(&Spec{T: loadTestSpecFile(t, "testdata/mocking.yaml")}, nil)
+	require.NoError(t, err)
+
+	source, err := os.ReadFile("testdata/mocking.yaml")
+	require.NoError(t, err)
+	SetLines(findings, source)
+
+	require.Len(t, findings, 1)
+	assert.Equal(Finding{
+		Rule:     "x-kusk",
+		Severity: Error,
+		Location: "paths./pets.get.x-kusk.mocking",
+		Message:  "validation.request.enabled and mocking.enabled must both be set when validation and mocking are combined",
+		Line:     17,
+	}, findings[0])
+}
+
+func TestLintConfig(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	findings, err := Lint(&Spec{T: loadTestSpec(t)}, Config{"x-kusk": Warning, "operation-id": Off, "response-examples": Info})
+	require.NoError(t, err)
+
+	var rules []string
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule+" "+string(finding.Severity))
+	}
+	// upstream services are only checked when they can be resolved
+	assert.Equal([]string{"x-kusk warning", "response-examples info"}, rules)
+	assert.False(HasErrors(findings))
+
+	_, err = Lint(&Spec{T: loadTestSpec(t)}, Config{"operation-ids": Off})
+	assert.EqualError(err, `unknown lint rule "operation-ids", expected one of x-kusk, upstream, operation-id, response-examples`)
+
+	_, err = Lint(&Spec{T: loadTestSpec(t)}, Config{"operation-id": "fatal"})
+	assert.EqualError(err, `invalid severity "fatal" for lint rule operation-id, expected error, warning, info or off`)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var b bytes.Buffer
+	require.NoError(t, WriteSARIF(&b, []Finding{
+		{Rule: "response-examples", Severity: Info, Location: "paths./pets.post", Message: "no example", Line: 29},
+	}, "spec.yaml", "v1.0.0"))
+
+	var log map[string]interface{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &log))
+	assert.Equal("2.1.0", log["version"])
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	assert.Len(run["tool"].(map[string]interface{})["driver"].(map[string]interface{})["rules"], len(Rules))
+	assert.JSONEq(`[{
+		"ruleId": "response-examples",
+		"level": "note",
+		"message": {"text": "no example"},
+		"locations": [{
+			"physicalLocation": {"artifactLocation": {"uri": "spec.yaml"}, "region": {"startLine": 29}},
+			"logicalLocations": [{"fullyQualifiedName": "paths./pets.post"}]
+		}]
+	}]`, mustMarshal(t, run["results"]))
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return string(b)
+}
@@ -0,0 +1,174 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk/internal/extension"
+)
+
+func checkExtension(spec *Spec) []Finding {
+	var findings []Finding
+	for _, err := range extension.Validate(spec.T) {
+		findings = append(findings, Finding{Location: err.Location, Message: err.Err.Error()})
+	}
+
+	return findings
+}
+
+func checkUpstreams(spec *Spec) []Finding {
+	// invalid options are reported by the x-kusk rule
+	levels, _ := extension.Levels(spec.T)
+
+	var findings []Finding
+	for _, level := range levels {
+		effective := level.Effective
+		if level.Operation != "" && (effective.Disabled == nil || !*effective.Disabled) &&
+			effective.Upstream == nil && effective.Redirect == nil && (effective.Mocking == nil || effective.Mocking.Enabled == nil || !*effective.Mocking.Enabled) {
+			findings = append(findings, Finding{
+				Location: level.Location,
+				Message:  fmt.Sprintf("%s has no upstream, redirect or mocking to route to", level.Operation),
+			})
+		}
+
+		upstream := level.Options.Upstream
+		if spec.Services == nil || upstream == nil || upstream.Service == nil {
+			continue
+		}
+
+		service := *upstream.Service
+		service.FillDefaults()
+		ports, err := spec.Services(service.Namespace, service.Name)
+		if err != nil {
+			findings = append(findings, Finding{
+				Location: level.Location + ".upstream.service",
+				Message:  fmt.Sprintf("service %s/%s can't be resolved: %s", service.Namespace, service.Name, err),
+			})
+			continue
+		}
+
+		if !containsPort(ports, int64(service.Port)) {
+			findings = append(findings, Finding{
+				Location: level.Location + ".upstream.service.port",
+				Message:  fmt.Sprintf("service %s/%s doesn't expose port %d, it exposes %s", service.Namespace, service.Name, service.Port, formatPorts(ports)),
+			})
+		}
+	}
+
+	return findings
+}
+
+func containsPort(ports []int64, port int64) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatPorts(ports []int64) string {
+	if len(ports) == 0 {
+		return "none"
+	}
+
+	formatted := make([]string, 0, len(ports))
+	for _, port := range ports {
+		formatted = append(formatted, fmt.Sprint(port))
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func checkOperationIDs(spec *Spec) []Finding {
+	var findings []Finding
+	operationsByID := map[string][]string{}
+	for _, path := range extension.SortedPaths(spec.Paths) {
+		operations := spec.Paths[path].Operations()
+		for _, method := range extension.SortedMethods(operations) {
+			operation := operations[method]
+			if operation.OperationID == "" {
+				findings = append(findings, Finding{
+					Location: "paths." + path + "." + strings.ToLower(method),
+					Message:  fmt.Sprintf("%s %s has no operationId", method, path),
+				})
+				continue
+			}
+
+			operationsByID[operation.OperationID] = append(operationsByID[operation.OperationID], method+" "+path)
+		}
+	}
+
+	ids := make([]string, 0, len(operationsByID))
+	for id := range operationsByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if operations := operationsByID[id]; len(operations) > 1 {
+			findings = append(findings, Finding{
+				Location: "paths",
+				Message:  fmt.Sprintf("operationId %s is used by %s", id, strings.Join(operations, ", ")),
+			})
+		}
+	}
+
+	return findings
+}
+
+func checkResponseExamples(spec *Spec) []Finding {
+	var findings []Finding
+	for _, path := range extension.SortedPaths(spec.Paths) {
+		operations := spec.Paths[path].Operations()
+		for _, method := range extension.SortedMethods(operations) {
+			responses := operations[method].Responses
+			statuses := make([]string, 0, len(responses))
+			for status := range responses {
+				statuses = append(statuses, status)
+			}
+			sort.Strings(statuses)
+
+			for _, status := range statuses {
+				response := responses[status].Value
+				if response == nil {
+					continue
+				}
+
+				contentTypes := make([]string, 0, len(response.Content))
+				for contentType := range response.Content {
+					contentTypes = append(contentTypes, contentType)
+				}
+				sort.Strings(contentTypes)
+
+				for _, contentType := range contentTypes {
+					if hasExample(response.Content[contentType]) {
+						continue
+					}
+
+					findings = append(findings, Finding{
+						Location: fmt.Sprintf("paths.%s.%s.responses.%s.content.%s", path, strings.ToLower(method), status, contentType),
+						Message:  fmt.Sprintf("%s %s responds %s %s without an example", method, path, status, contentType),
+					})
+				}
+			}
+		}
+	}
+
+	return findings
+}
+
+func hasExample(media *openapi3.MediaType) bool {
+	if media == nil {
+		return true
+	}
+	if media.Example != nil || len(media.Examples) > 0 {
+		return true
+	}
+
+	return media.Schema != nil && media.Schema.Value != nil && media.Schema.Value.Example != nil
+}
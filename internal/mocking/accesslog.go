@@ -0,0 +1,74 @@
+package mocking
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Sources of the responses served by a mock server
+const (
+	// SourceExample responses are examples from the spec
+	SourceExample = "example"
+	// SourceSchema responses are generated from the schema in the spec
+	SourceSchema = "schema"
+	// SourceFixture responses were recorded from a real upstream
+	SourceFixture = "fixture"
+	// SourceUpstream responses are proxied from a real upstream
+	SourceUpstream = "upstream"
+)
+
+// AccessLogEntry describes a request served by a mock server
+type AccessLogEntry struct {
+	TimeStamp time.Time
+	Method    string
+	Path      string
+	// StatusCode is "-" when no response was sent
+	StatusCode string
+	// Latency is the time taken to serve the request, when known
+	Latency time.Duration
+
+	// Spec is the name of the spec that served the request when serving several specs
+	Spec string
+	// Operation is the operationId of the operation served, or its method and path when it has none
+	Operation string
+	// Source tells where the response body came from, one of the Source constants, when known
+	Source string
+	// Scenario describes the scenario step played back for the request, if any
+	Scenario string
+
+	Error error
+}
+
+// MarshalJSON writes the entry as a flat object for tools ingesting the mock server's traffic
+func (e AccessLogEntry) MarshalJSON() ([]byte, error) {
+	entry := struct {
+		TimeStamp   time.Time `json:"timestamp"`
+		Spec        string    `json:"spec,omitempty"`
+		Method      string    `json:"method"`
+		Path        string    `json:"path"`
+		OperationID string    `json:"operationId,omitempty"`
+		Status      int       `json:"status,omitempty"`
+		LatencyMs   float64   `json:"latencyMs"`
+		Source      string    `json:"source,omitempty"`
+		Scenario    string    `json:"scenario,omitempty"`
+		Error       string    `json:"error,omitempty"`
+	}{
+		TimeStamp:   e.TimeStamp,
+		Spec:        e.Spec,
+		Method:      e.Method,
+		Path:        e.Path,
+		OperationID: e.Operation,
+		LatencyMs:   float64(e.Latency.Microseconds()) / 1000,
+		Source:      e.Source,
+		Scenario:    e.Scenario,
+	}
+
+	// no status is written when no response was sent
+	entry.Status, _ = strconv.Atoi(e.StatusCode)
+	if e.Error != nil {
+		entry.Error = e.Error.Error()
+	}
+
+	return json.Marshal(entry)
+}
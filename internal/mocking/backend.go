@@ -0,0 +1,36 @@
+package mocking
+
+import (
+	"context"
+	"errors"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// ErrBackendExited is reported on a backend's Errors channel when the mock server stops unexpectedly
+var ErrBackendExited = errors.New("mock server exited unexpectedly")
+
+// Backend is a mock server implementation that kusk mock can serve an API with,
+// e.g. an in-process server or a container run through a Docker compatible daemon
+type Backend interface {
+	// Start starts serving the mocked API
+	Start(ctx context.Context) error
+	// Stop shuts the mock server down
+	Stop(ctx context.Context) error
+	// Reload makes the mock server serve the given version of the API specs
+	Reload(ctx context.Context, specs []Spec) error
+	// Logs returns the channel that access log entries for served requests are sent on
+	Logs() <-chan AccessLogEntry
+	// Errors returns the channel that errors occurring while serving are sent on.
+	// Errors wrapping ErrBackendExited mean the mock server is no longer serving.
+	Errors() <-chan error
+}
+
+// Spec is an API spec served by a mock server. Several specs can be served on the same port under different path prefixes.
+type Spec struct {
+	// Name identifies the spec in access logs when serving several specs
+	Name string
+	// Prefix is the path the API is served under, overriding the x-kusk path prefix of the spec when set
+	Prefix string
+	API    *openapi3.T
+}
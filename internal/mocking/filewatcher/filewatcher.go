@@ -3,13 +3,33 @@ package filewatcher
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/ghodss/yaml"
 )
 
+// debounceInterval is how long the watcher waits for more changes before reporting them,
+// so saving several files at once or editors writing a file in several steps trigger a single reload
+const debounceInterval = 250 * time.Millisecond
+
+// FileWatcher watches an OpenAPI spec along with all the files it references through external $refs.
+// The directories of the files are watched rather than the files themselves, so changes made by editors
+// saving through a rename of a temporary file are noticed as well.
 type FileWatcher struct {
-	watcher *fsnotify.Watcher
+	watcher  *fsnotify.Watcher
+	filePath string
+	debounce time.Duration
+
+	mu    sync.Mutex
+	files map[string]bool
+	dirs  map[string]bool
 }
 
 func New(filePath string) (*FileWatcher, error) {
@@ -18,25 +38,105 @@ func New(filePath string) (*FileWatcher, error) {
 		return nil, fmt.Errorf("unable to create new file watcher: %w", err)
 	}
 
-	if err := watcher.Add(filePath); err != nil {
-		return nil, fmt.Errorf("unable to add file %s to watcher: %w", filePath, err)
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileWatcher{
+		watcher:  watcher,
+		filePath: absPath,
+		debounce: debounceInterval,
+		dirs:     map[string]bool{},
+	}
+
+	if err := f.refresh(); err != nil {
+		watcher.Close()
+		return nil, err
 	}
 
-	return &FileWatcher{
-		watcher: watcher,
-	}, nil
+	return f, nil
 }
 
-func (f *FileWatcher) Watch(fu func(), cancelCh chan os.Signal) {
+// Files returns the spec and the files it references
+func (f *FileWatcher) Files() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	files := make([]string, 0, len(f.files))
+	for file := range f.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// refresh resolves the files referenced by the spec again and watches any new directory they are in
+func (f *FileWatcher) refresh() error {
+	files := map[string]bool{}
+	resolveRefs(f.filePath, files)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files = files
+	for file := range files {
+		dir := filepath.Dir(file)
+		if f.dirs[dir] {
+			continue
+		}
+
+		if err := f.watcher.Add(dir); err != nil {
+			if file == f.filePath {
+				return fmt.Errorf("unable to add file %s to watcher: %w", f.filePath, err)
+			}
+			// referenced files may not exist yet, the spec will fail to load until they do
+			continue
+		}
+		f.dirs[dir] = true
+	}
+
+	return nil
+}
+
+func (f *FileWatcher) watches(file string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.files[filepath.Clean(file)]
+}
+
+// Watch calls fu once changes to the watched files settle, until the watcher is closed
+func (f *FileWatcher) Watch(fu func()) {
+	var (
+		timer    *time.Timer
+		debounce <-chan time.Time
+	)
+
 	for {
 		select {
 		case event, ok := <-f.watcher.Events:
 			if !ok {
 				return
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				fu()
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 || !f.watches(event.Name) {
+				continue
 			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(f.debounce)
+			debounce = timer.C
+		case <-debounce:
+			debounce = nil
+
+			// the changes may have added or removed references
+			if err := f.refresh(); err != nil {
+				log.Println("error:", err)
+			}
+			fu()
 		case err, ok := <-f.watcher.Errors:
 			if !ok {
 				// channel closed
@@ -45,9 +145,6 @@ func (f *FileWatcher) Watch(fu func(), cancelCh chan os.Signal) {
 			if err != nil {
 				log.Println("error:", err)
 			}
-		case <-cancelCh:
-			f.Close()
-			return
 		}
 	}
 }
@@ -55,3 +152,58 @@ func (f *FileWatcher) Watch(fu func(), cancelCh chan os.Signal) {
 func (f *FileWatcher) Close() {
 	f.watcher.Close()
 }
+
+// resolveRefs adds the file and the files it references through external $refs, recursively, to files
+func resolveRefs(file string, files map[string]bool) {
+	file = filepath.Clean(file)
+	if files[file] {
+		return
+	}
+	files[file] = true
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return
+	}
+
+	for _, ref := range collectRefs(doc, nil) {
+		path := strings.SplitN(ref, "#", 2)[0]
+		if path == "" {
+			continue
+		}
+
+		// references to remote documents can't be watched
+		if u, err := url.Parse(path); err == nil && u.Scheme != "" && u.Host != "" {
+			continue
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		resolveRefs(path, files)
+	}
+}
+
+func collectRefs(node interface{}, refs []string) []string {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				refs = append(refs, ref)
+				continue
+			}
+			refs = collectRefs(value, refs)
+		}
+	case []interface{}:
+		for _, value := range n {
+			refs = collectRefs(value, refs)
+		}
+	}
+
+	return refs
+}
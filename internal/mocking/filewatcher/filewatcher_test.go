@@ -0,0 +1,99 @@
+package filewatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `openapi: 3.0.0
+info:
+  title: users
+  version: 0.0.1
+paths:
+  /users:
+    get:
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: './schemas/user.yaml#/User'
+  /remote:
+    get:
+      responses:
+        '200':
+          $ref: 'https://example.com/responses.yaml#/Ok'
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestFileWatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	schemaPath := filepath.Join(dir, "schemas", "user.yaml")
+	addressPath := filepath.Join(dir, "schemas", "address.yaml")
+
+	writeFile(t, specPath, testSpec)
+	writeFile(t, schemaPath, "User:\n  type: object\n  properties:\n    address:\n      $ref: 'address.yaml#/Address'\n")
+	writeFile(t, addressPath, "Address:\n  type: string\n")
+	writeFile(t, filepath.Join(dir, "unrelated.yaml"), "")
+
+	watcher, err := New(specPath)
+	require.NoError(t, err)
+	defer watcher.Close()
+	watcher.debounce = 50 * time.Millisecond
+
+	assert.Equal([]string{addressPath, schemaPath, specPath}, watcher.Files())
+
+	changes := make(chan struct{}, 10)
+	go watcher.Watch(func() {
+		changes <- struct{}{}
+	})
+
+	expectChange := func(msg string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no change detected: " + msg)
+		}
+		select {
+		case <-changes:
+			t.Fatal("changes should be debounced: " + msg)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	writeFile(t, addressPath, "Address:\n  type: object\n")
+	expectChange("write to a nested reference")
+
+	for i := 0; i < 5; i++ {
+		writeFile(t, schemaPath, "User:\n  type: object\n")
+	}
+	expectChange("burst of writes")
+	assert.Equal([]string{schemaPath, specPath}, watcher.Files(), "references should be resolved again")
+
+	tmpPath := filepath.Join(dir, ".spec.yaml.swp")
+	writeFile(t, tmpPath, testSpec)
+	require.NoError(t, os.Rename(tmpPath, specPath))
+	expectChange("atomic save through a rename")
+
+	writeFile(t, filepath.Join(dir, "unrelated.yaml"), "changed")
+	select {
+	case <-changes:
+		t.Fatal("unrelated files should not trigger changes")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
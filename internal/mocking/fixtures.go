@@ -0,0 +1,105 @@
+package mocking
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Fixture is a request and response pair recorded from a real upstream and matched to an operation of the spec
+type Fixture struct {
+	// Operation is the operationId of the operation, or its method and path such as "GET /todos/{id}"
+	Operation string          `json:"operation"`
+	Request   FixtureRequest  `json:"request"`
+	Response  FixtureResponse `json:"response"`
+}
+
+// FixtureRequest is the request a fixture was recorded for
+type FixtureRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+}
+
+// FixtureResponse is the response the upstream served
+type FixtureResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body holds JSON bodies as is, any other text body as a JSON string
+	// and binary bodies as a base64 JSON string, as told by BodyEncoding
+	Body         json.RawMessage `json:"body,omitempty"`
+	BodyEncoding string          `json:"bodyEncoding,omitempty"`
+}
+
+// BodyEncodingBase64 marks bodies that aren't text and are stored base64 encoded
+const BodyEncodingBase64 = "base64"
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// FileName names the fixture after its operation and request,
+// so recording the same request again replaces the previous fixture
+func (f Fixture) FileName() string {
+	operation := strings.Trim(unsafeFileNameChars.ReplaceAllString(f.Operation, "_"), "_")
+	hash := sha256.Sum256([]byte(f.Request.Method + " " + f.Request.Path + "?" + f.Request.Query))
+
+	return fmt.Sprintf("%s-%x.json", operation, hash[:4])
+}
+
+// SaveFixture writes the fixture to the fixtures directory and returns the path of the fixture file
+func SaveFixture(dir string, fixture Fixture) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("unable to create fixtures directory %s: %w", dir, err)
+	}
+
+	b, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to encode fixture: %w", err)
+	}
+
+	path := filepath.Join(dir, fixture.FileName())
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("unable to write fixture %s: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// LoadFixtures reads all fixtures from the fixtures directory
+func LoadFixtures(dir string) ([]Fixture, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("unable to read fixtures directory: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read fixture %s: %w", path, err)
+		}
+
+		var fixture Fixture
+		if err := json.Unmarshal(b, &fixture); err != nil {
+			return nil, fmt.Errorf("unable to parse fixture %s: %w", path, err)
+		}
+
+		if fixture.Operation == "" || fixture.Request.Method == "" || fixture.Response.Status == 0 {
+			return nil, fmt.Errorf("invalid fixture %s: operation, request.method and response.status are required", path)
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, nil
+}
@@ -0,0 +1,356 @@
+package generator
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// maxDepth stops recursive schemas from generating infinitely nested values
+const maxDepth = 10
+
+var words = []string{
+	"ad", "alias", "aliquam", "amet", "animi", "aperiam", "architecto", "asperiores", "aut", "autem",
+	"beatae", "blanditiis", "commodi", "consequatur", "corporis", "culpa", "cum", "cumque", "debitis", "deleniti",
+	"dolor", "dolore", "dolorem", "ducimus", "ea", "eaque", "earum", "eius", "eligendi", "enim",
+	"eos", "error", "esse", "est", "et", "eum", "eveniet", "ex", "excepturi", "explicabo",
+	"facere", "facilis", "fuga", "fugiat", "harum", "hic", "id", "illo", "impedit", "inventore",
+	"ipsa", "ipsam", "iste", "itaque", "iure", "labore", "laborum", "laudantium", "magnam", "magni",
+	"maiores", "minima", "minus", "modi", "molestiae", "mollitia", "nam", "natus", "nemo", "nihil",
+	"nisi", "nobis", "non", "nostrum", "nulla", "numquam", "odio", "officia", "omnis", "optio",
+	"pariatur", "perferendis", "placeat", "porro", "possimus", "quae", "quam", "quas", "quia", "quibusdam",
+	"quidem", "quis", "quo", "quod", "ratione", "recusandae", "rem", "rerum", "saepe", "sapiente",
+	"sed", "sint", "sit", "sunt", "tempora", "tempore", "totam", "ullam", "unde", "ut",
+	"vel", "velit", "veniam", "veritatis", "vero", "vitae", "voluptas", "voluptate", "voluptatem", "voluptatum",
+}
+
+// Options bound the values generated for schemas that don't set their own bounds
+type Options struct {
+	// NullProbability is the probability of generating null for nullable schemas
+	NullProbability float64
+
+	MinItems, MaxItems   int
+	MinLength, MaxLength int
+	MinInt, MaxInt       int64
+	MinFloat, MaxFloat   float64
+}
+
+// DefaultOptions returns the options a Generator starts with
+func DefaultOptions() Options {
+	return Options{
+		NullProbability: 0.5,
+		MinItems:        1,
+		MaxItems:        5,
+		MinLength:       0,
+		MaxLength:       64,
+		MinInt:          0,
+		MaxInt:          math.MaxInt32,
+		MinFloat:        -math.MaxInt32 / 2,
+		MaxFloat:        math.MaxInt32 / 2,
+	}
+}
+
+// Generator produces random values that conform to OpenAPI schemas.
+// A Generator is not safe for concurrent use.
+type Generator struct {
+	rand *rand.Rand
+
+	// IgnoreExamples makes the generator produce values even when the schema defines an example
+	IgnoreExamples bool
+	Options        Options
+}
+
+// New returns a Generator drawing its random values from source
+func New(source rand.Source) *Generator {
+	return &Generator{
+		rand:    rand.New(source),
+		Options: DefaultOptions(),
+	}
+}
+
+// Generate returns a value matching the given schema.
+// Examples and defaults defined on the schema take precedence over generated values.
+func (g *Generator) Generate(schema *openapi3.Schema) interface{} {
+	return g.generate(schema, 0)
+}
+
+func (g *Generator) generate(schema *openapi3.Schema, depth int) interface{} {
+	if schema == nil || depth > maxDepth {
+		return nil
+	}
+
+	if schema.Example != nil && !g.IgnoreExamples {
+		return schema.Example
+	}
+
+	if schema.Nullable && g.rand.Float64() < g.Options.NullProbability {
+		return nil
+	}
+
+	if len(schema.Enum) > 0 {
+		return schema.Enum[g.rand.Intn(len(schema.Enum))]
+	}
+
+	if schema.Default != nil {
+		return schema.Default
+	}
+
+	switch {
+	case len(schema.AllOf) > 0:
+		return g.generateAllOf(schema, depth)
+	case len(schema.OneOf) > 0:
+		return g.generateRef(schema.OneOf[g.rand.Intn(len(schema.OneOf))], depth)
+	case len(schema.AnyOf) > 0:
+		return g.generateRef(schema.AnyOf[g.rand.Intn(len(schema.AnyOf))], depth)
+	}
+
+	switch schema.Type {
+	case openapi3.TypeString:
+		return g.generateString(schema)
+	case openapi3.TypeInteger:
+		return g.generateInteger(schema)
+	case openapi3.TypeNumber:
+		return g.generateNumber(schema)
+	case openapi3.TypeBoolean:
+		return g.rand.Intn(2) == 1
+	case openapi3.TypeArray:
+		return g.generateArray(schema, depth)
+	case openapi3.TypeObject:
+		return g.generateObject(schema, depth)
+	}
+
+	// the type is optional in OpenAPI, so fall back to what the rest of the schema implies
+	switch {
+	case len(schema.Properties) > 0 || schema.AdditionalProperties != nil:
+		return g.generateObject(schema, depth)
+	case schema.Items != nil:
+		return g.generateArray(schema, depth)
+	}
+
+	return nil
+}
+
+func (g *Generator) generateRef(ref *openapi3.SchemaRef, depth int) interface{} {
+	if ref == nil {
+		return nil
+	}
+
+	return g.generate(ref.Value, depth+1)
+}
+
+// generateAllOf merges the objects generated from each of the schemas.
+// For non object schemas the value generated from the last schema wins.
+func (g *Generator) generateAllOf(schema *openapi3.Schema, depth int) interface{} {
+	var result interface{}
+	merged := map[string]interface{}{}
+	for _, ref := range schema.AllOf {
+		value := g.generateRef(ref, depth)
+		if object, ok := value.(map[string]interface{}); ok {
+			for k, v := range object {
+				merged[k] = v
+			}
+			result = merged
+			continue
+		}
+
+		result = value
+	}
+
+	if len(schema.Properties) > 0 {
+		for k, v := range g.generateObject(schema, depth) {
+			merged[k] = v
+		}
+		result = merged
+	}
+
+	return result
+}
+
+func (g *Generator) generateString(schema *openapi3.Schema) interface{} {
+	switch schema.Format {
+	case "date":
+		return g.randomTime().Format("2006-01-02")
+	case "date-time":
+		return g.randomTime().Format(time.RFC3339)
+	case "time":
+		return g.randomTime().Format("15:04:05")
+	case "email":
+		return fmt.Sprintf("%s.%s@%s.com", g.word(), g.word(), g.word())
+	case "uri", "url":
+		return fmt.Sprintf("http://%s.com/%s", g.word(), g.word())
+	case "hostname":
+		return fmt.Sprintf("%s.%s.com", g.word(), g.word())
+	case "ipv4":
+		return fmt.Sprintf("%d.%d.%d.%d", g.rand.Intn(256), g.rand.Intn(256), g.rand.Intn(256), g.rand.Intn(256))
+	case "ipv6":
+		groups := make([]string, 8)
+		for i := range groups {
+			groups[i] = fmt.Sprintf("%x", g.rand.Intn(0x10000))
+		}
+		return strings.Join(groups, ":")
+	case "uuid":
+		b := make([]byte, 16)
+		g.rand.Read(b)
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(g.sentence()))
+	}
+
+	s := g.sentence()
+
+	minLength, maxLength := uint64(g.Options.MinLength), uint64(g.Options.MaxLength)
+	if schema.MinLength > 0 {
+		minLength = schema.MinLength
+	}
+	if schema.MaxLength != nil {
+		maxLength = *schema.MaxLength
+	}
+	if minLength > maxLength {
+		maxLength = minLength
+	}
+
+	for uint64(len(s)) < minLength {
+		s += " " + g.sentence()
+	}
+
+	if uint64(len(s)) > maxLength {
+		s = s[:maxLength]
+	}
+
+	return s
+}
+
+func (g *Generator) generateInteger(schema *openapi3.Schema) interface{} {
+	min, max := float64(g.Options.MinInt), float64(g.Options.MaxInt)
+	// int64 values use the whole range unless the maximum was changed from the default
+	if schema.Format == "int64" && g.Options.MaxInt == DefaultOptions().MaxInt {
+		max = math.MaxInt64 / 2
+	}
+	if schema.Min != nil {
+		min = math.Ceil(*schema.Min)
+		if schema.ExclusiveMin {
+			min++
+		}
+	}
+	if schema.Max != nil {
+		max = math.Floor(*schema.Max)
+		if schema.ExclusiveMax {
+			max--
+		}
+	}
+	if min > max {
+		return int64(min)
+	}
+
+	value := int64(min) + g.rand.Int63n(int64(max-min)+1)
+
+	if schema.MultipleOf != nil && *schema.MultipleOf >= 1 {
+		multipleOf := int64(*schema.MultipleOf)
+		value -= value % multipleOf
+		if float64(value) < min {
+			value += multipleOf
+		}
+	}
+
+	return value
+}
+
+func (g *Generator) generateNumber(schema *openapi3.Schema) interface{} {
+	min, max := g.Options.MinFloat, g.Options.MaxFloat
+	if schema.Min != nil {
+		min = *schema.Min
+	}
+	if schema.Max != nil {
+		max = *schema.Max
+	}
+	if min > max {
+		return min
+	}
+
+	value := min + g.rand.Float64()*(max-min)
+
+	if schema.MultipleOf != nil && *schema.MultipleOf > 0 {
+		value = math.Floor(value / *schema.MultipleOf) * *schema.MultipleOf
+	}
+
+	return value
+}
+
+func (g *Generator) generateArray(schema *openapi3.Schema, depth int) interface{} {
+	min, max := uint64(g.Options.MinItems), uint64(g.Options.MaxItems)
+	if schema.MinItems > 0 {
+		min = schema.MinItems
+	}
+	if schema.MaxItems != nil {
+		max = *schema.MaxItems
+	}
+	if min > max {
+		max = min
+	}
+
+	length := min + uint64(g.rand.Int63n(int64(max-min)+1))
+
+	items := make([]interface{}, 0, length)
+	for i := uint64(0); i < length; i++ {
+		items = append(items, g.generateRef(schema.Items, depth))
+	}
+
+	return items
+}
+
+func (g *Generator) generateObject(schema *openapi3.Schema, depth int) map[string]interface{} {
+	object := map[string]interface{}{}
+
+	// iterate in a stable order so the same random source always produces the same object
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := schema.Properties[name]
+		if property.Value != nil && property.Value.WriteOnly {
+			continue
+		}
+
+		object[name] = g.generateRef(property, depth)
+	}
+
+	if schema.AdditionalProperties != nil && len(object) == 0 {
+		for i := 0; i < g.Options.MinItems+g.rand.Intn(g.Options.MaxItems-g.Options.MinItems+1); i++ {
+			object[g.word()] = g.generateRef(schema.AdditionalProperties, depth)
+		}
+	}
+
+	return object
+}
+
+func (g *Generator) randomTime() time.Time {
+	// anywhere between 2000 and 2030 is a realistic enough date
+	const start, end = 946684800, 1893456000
+	return time.Unix(start+g.rand.Int63n(end-start), 0).UTC()
+}
+
+func (g *Generator) word() string {
+	return words[g.rand.Intn(len(words))]
+}
+
+func (g *Generator) sentence() string {
+	n := 2 + g.rand.Intn(4)
+	sentence := make([]string, n)
+	for i := range sentence {
+		sentence[i] = g.word()
+	}
+
+	s := strings.Join(sentence, " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
@@ -0,0 +1,129 @@
+package generator
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	min, max := float64(10), float64(20)
+	maxLength, maxItems := uint64(8), uint64(3)
+
+	testCases := []struct {
+		name   string
+		schema *openapi3.Schema
+		check  func(*assert.Assertions, interface{})
+	}{
+		{
+			name:   "example wins",
+			schema: &openapi3.Schema{Type: openapi3.TypeString, Example: "example"},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.Equal("example", v)
+			},
+		},
+		{
+			name:   "enum",
+			schema: &openapi3.Schema{Type: openapi3.TypeString, Enum: []interface{}{"a", "b"}},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.Contains([]interface{}{"a", "b"}, v)
+			},
+		},
+		{
+			name:   "bounded integer",
+			schema: &openapi3.Schema{Type: openapi3.TypeInteger, Min: &min, Max: &max},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.GreaterOrEqual(v.(int64), int64(10))
+				assert.LessOrEqual(v.(int64), int64(20))
+			},
+		},
+		{
+			name:   "bounded string",
+			schema: &openapi3.Schema{Type: openapi3.TypeString, MaxLength: &maxLength},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.LessOrEqual(len(v.(string)), 8)
+			},
+		},
+		{
+			name: "bounded array",
+			schema: &openapi3.Schema{
+				Type:     openapi3.TypeArray,
+				MaxItems: &maxItems,
+				Items:    openapi3.NewSchemaRef("", openapi3.NewBoolSchema()),
+			},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.NotEmpty(v)
+				assert.LessOrEqual(len(v.([]interface{})), 3)
+			},
+		},
+		{
+			name: "object without write only properties",
+			schema: &openapi3.Schema{
+				Type: openapi3.TypeObject,
+				Properties: openapi3.Schemas{
+					"name":     openapi3.NewSchemaRef("", openapi3.NewStringSchema()),
+					"password": openapi3.NewSchemaRef("", &openapi3.Schema{Type: openapi3.TypeString, WriteOnly: true}),
+				},
+			},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.Contains(v, "name")
+				assert.NotContains(v, "password")
+			},
+		},
+		{
+			name: "all of merges objects",
+			schema: &openapi3.Schema{
+				AllOf: openapi3.SchemaRefs{
+					openapi3.NewSchemaRef("", openapi3.NewObjectSchema().WithProperty("a", openapi3.NewStringSchema())),
+					openapi3.NewSchemaRef("", openapi3.NewObjectSchema().WithProperty("b", openapi3.NewInt64Schema())),
+				},
+			},
+			check: func(assert *assert.Assertions, v interface{}) {
+				assert.Contains(v, "a")
+				assert.Contains(v, "b")
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			testCase.check(assert.New(t), New(rand.NewSource(1)).Generate(testCase.schema))
+		})
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	gen := New(rand.NewSource(1))
+	gen.Options.MinItems, gen.Options.MaxItems = 7, 7
+	gen.Options.MinLength, gen.Options.MaxLength = 100, 120
+	gen.Options.MinInt, gen.Options.MaxInt = 5, 6
+
+	items := gen.Generate(openapi3.NewArraySchema().WithItems(openapi3.NewBoolSchema()))
+	assert.Len(items, 7)
+
+	s := gen.Generate(openapi3.NewStringSchema()).(string)
+	assert.GreaterOrEqual(len(s), 100)
+	assert.LessOrEqual(len(s), 120)
+
+	i := gen.Generate(openapi3.NewIntegerSchema()).(int64)
+	assert.True(i == 5 || i == 6)
+
+	nullable := openapi3.NewStringSchema()
+	nullable.Nullable = true
+
+	gen.Options.NullProbability = 1
+	assert.Nil(gen.Generate(nullable))
+
+	gen.Options.NullProbability = 0
+	assert.NotNil(gen.Generate(nullable))
+}
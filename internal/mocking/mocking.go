@@ -1,20 +1,282 @@
 package mocking
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"os"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/kubeshop/kusk/internal/mocking/generator"
 )
 
-const openApiMockingConfigStr = `application:
-  debug: false
-  log_format: json
-  log_level: warn
+// ConfigVersion is the version of the mocking config written by this version of kusk.
+// Files with an older version, or none at all, are migrated when they're loaded.
+const ConfigVersion = 2
+
+// use_examples policies of the mocking config
+const (
+	UseExamplesNo          = "no"
+	UseExamplesIfPresent   = "if_present"
+	UseExamplesExclusively = "exclusively"
+)
+
+// Config is the mocking config kept in $HOME/.kusk/openapi-mock.yaml. Its format is the one of the
+// openapi-mock container, which the native mock server follows, along with the settings only the native mock server uses.
+type Config struct {
+	Version     int               `json:"version" mapstructure:"version"`
+	Application ApplicationConfig `json:"application" mapstructure:"application"`
+	Generation  GenerationConfig  `json:"generation" mapstructure:"generation"`
+	// Security is only used by the native mock server
+	Security *SecurityConfig `json:"security,omitempty" mapstructure:"security"`
+}
+
+// ApplicationConfig configures the logging of the openapi-mock container
+type ApplicationConfig struct {
+	CORSEnabled     bool    `json:"cors_enabled" mapstructure:"cors_enabled"`
+	ResponseTimeout float64 `json:"response_timeout" mapstructure:"response_timeout"`
+	Debug           bool    `json:"debug" mapstructure:"debug"`
+	LogFormat       string  `json:"log_format" mapstructure:"log_format"`
+	LogLevel        string  `json:"log_level" mapstructure:"log_level"`
+}
+
+// GenerationConfig configures how responses are generated from the schemas
+type GenerationConfig struct {
+	// UseExamples is either no, if_present or exclusively
+	UseExamples string `json:"use_examples" mapstructure:"use_examples"`
+	// SuppressErrors serves what could be generated instead of an error when generating a response fails
+	SuppressErrors bool `json:"suppress_errors" mapstructure:"suppress_errors"`
+	// NullProbability is the probability of generating null for nullable values
+	NullProbability float64 `json:"null_probability" mapstructure:"null_probability"`
+
+	// the bounds below apply to schemas that don't set their own
+	DefaultMinItems  int     `json:"default_min_items" mapstructure:"default_min_items"`
+	DefaultMaxItems  int     `json:"default_max_items" mapstructure:"default_max_items"`
+	DefaultMinLength int     `json:"default_min_length" mapstructure:"default_min_length"`
+	DefaultMaxLength int     `json:"default_max_length" mapstructure:"default_max_length"`
+	DefaultMinInt    int64   `json:"default_min_int" mapstructure:"default_min_int"`
+	DefaultMaxInt    int64   `json:"default_max_int" mapstructure:"default_max_int"`
+	DefaultMinFloat  float64 `json:"default_min_float" mapstructure:"default_min_float"`
+	DefaultMaxFloat  float64 `json:"default_max_float" mapstructure:"default_max_float"`
+
+	// Seed makes the native mock server generate the same response for the same operation and parameters
+	Seed *int64 `json:"seed,omitempty" mapstructure:"seed"`
+}
+
+// SecurityConfig lists the credentials accepted per security scheme of the spec when enforcing security
+type SecurityConfig struct {
+	// Tokens are the accepted credentials by security scheme name, any value is accepted for schemes without tokens.
+	// Basic auth credentials are user:password
+	Tokens map[string][]string `json:"tokens,omitempty" mapstructure:"tokens"`
+}
+
+// GeneratorOptions returns the bounds of the generated values set by the config
+func (g GenerationConfig) GeneratorOptions() generator.Options {
+	return generator.Options{
+		NullProbability: g.NullProbability,
+		MinItems:        g.DefaultMinItems,
+		MaxItems:        g.DefaultMaxItems,
+		MinLength:       g.DefaultMinLength,
+		MaxLength:       g.DefaultMaxLength,
+		MinInt:          g.DefaultMinInt,
+		MaxInt:          g.DefaultMaxInt,
+		MinFloat:        g.DefaultMinFloat,
+		MaxFloat:        g.DefaultMaxFloat,
+	}
+}
+
+// DefaultConfig returns the mocking config used when none was written yet
+func DefaultConfig() Config {
+	options := generator.DefaultOptions()
+
+	return Config{
+		Version: ConfigVersion,
+		Application: ApplicationConfig{
+			ResponseTimeout: 1,
+			LogFormat:       "json",
+			LogLevel:        "warn",
+		},
+		Generation: GenerationConfig{
+			UseExamples:      UseExamplesIfPresent,
+			NullProbability:  options.NullProbability,
+			DefaultMinItems:  options.MinItems,
+			DefaultMaxItems:  options.MaxItems,
+			DefaultMinLength: options.MinLength,
+			DefaultMaxLength: options.MaxLength,
+			DefaultMinInt:    options.MinInt,
+			DefaultMaxInt:    options.MaxInt,
+			DefaultMinFloat:  options.MinFloat,
+			DefaultMaxFloat:  options.MaxFloat,
+		},
+	}
+}
+
+// Validate reports the first setting of the config with an invalid value
+func (c Config) Validate() error {
+	switch c.Application.LogFormat {
+	case "tty", "json":
+	default:
+		return fmt.Errorf("invalid application.log_format %q, expected tty or json", c.Application.LogFormat)
+	}
+
+	switch c.Application.LogLevel {
+	case "error", "warn", "info", "debug", "trace":
+	default:
+		return fmt.Errorf("invalid application.log_level %q, expected error, warn, info, debug or trace", c.Application.LogLevel)
+	}
+
+	if c.Application.ResponseTimeout <= 0 {
+		return fmt.Errorf("invalid application.response_timeout %v, expected a positive number of seconds", c.Application.ResponseTimeout)
+	}
+
+	g := c.Generation
+	switch g.UseExamples {
+	case UseExamplesNo, UseExamplesIfPresent, UseExamplesExclusively:
+	default:
+		return fmt.Errorf("invalid generation.use_examples %q, expected %s, %s or %s", g.UseExamples, UseExamplesNo, UseExamplesIfPresent, UseExamplesExclusively)
+	}
+
+	switch {
+	case g.NullProbability < 0 || g.NullProbability > 1:
+		return fmt.Errorf("invalid generation.null_probability %v, expected a value between 0 and 1", g.NullProbability)
+	case g.DefaultMinItems < 0 || g.DefaultMinItems > g.DefaultMaxItems:
+		return fmt.Errorf("invalid generation.default_min_items %d and default_max_items %d, expected 0 <= min <= max", g.DefaultMinItems, g.DefaultMaxItems)
+	case g.DefaultMinLength < 0 || g.DefaultMinLength > g.DefaultMaxLength:
+		return fmt.Errorf("invalid generation.default_min_length %d and default_max_length %d, expected 0 <= min <= max", g.DefaultMinLength, g.DefaultMaxLength)
+	case g.DefaultMinInt > g.DefaultMaxInt:
+		return fmt.Errorf("invalid generation.default_min_int %d and default_max_int %d, expected min <= max", g.DefaultMinInt, g.DefaultMaxInt)
+	case g.DefaultMinFloat > g.DefaultMaxFloat:
+		return fmt.Errorf("invalid generation.default_min_float %v and default_max_float %v, expected min <= max", g.DefaultMinFloat, g.DefaultMaxFloat)
+	}
+
+	return nil
+}
 
-generation:
-  suppress_errors: false
-  use_examples: 'if_present'
+// configHeader documents the settings that aren't written unless they're set
+const configHeader = `# mocking config of kusk mock, shared by the native mock server and the openapi-mock container.
+# Settings can be overridden under mock: in $HOME/.kusk.yaml and with the flags of kusk mock.
+#
+# generation.seed: generate the same response for the same operation and parameters with the native mock server
+# security.tokens: credentials accepted per security scheme with --enforce-security, e.g.
+#   security:
+#     tokens:
+#       bearerAuth:
+#         - secret-token
+#       basicAuth:
+#         - user:password
 `
 
-func WriteMockingConfig(w io.Writer) error {
-	_, err := w.Write([]byte(openApiMockingConfigStr))
+// Write writes the config in the format of the config file
+func (c Config) Write(w io.Writer) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("unable to encode mocking config: %w", err)
+	}
+
+	_, err = w.Write(append([]byte(configHeader), b...))
 	return err
 }
+
+// openAPIMockConfig is the config file of the openapi-mock container, which only knows these settings
+type openAPIMockConfig struct {
+	HTTP struct {
+		CORSEnabled     bool    `json:"cors_enabled"`
+		ResponseTimeout float64 `json:"response_timeout"`
+	} `json:"http"`
+	Application struct {
+		Debug     bool   `json:"debug"`
+		LogFormat string `json:"log_format"`
+		LogLevel  string `json:"log_level"`
+	} `json:"application"`
+	Generation struct {
+		UseExamples     string  `json:"use_examples"`
+		SuppressErrors  bool    `json:"suppress_errors"`
+		NullProbability float64 `json:"null_probability"`
+		DefaultMinInt   int64   `json:"default_min_int"`
+		DefaultMaxInt   int64   `json:"default_max_int"`
+		DefaultMinFloat float64 `json:"default_min_float"`
+		DefaultMaxFloat float64 `json:"default_max_float"`
+	} `json:"generation"`
+}
+
+// WriteOpenAPIMock writes the settings of the config known to the openapi-mock container in the format of its config file.
+// The settings only the native mock server uses are left out.
+func (c Config) WriteOpenAPIMock(w io.Writer) error {
+	var config openAPIMockConfig
+	config.HTTP.CORSEnabled = c.Application.CORSEnabled
+	config.HTTP.ResponseTimeout = c.Application.ResponseTimeout
+	config.Application.Debug = c.Application.Debug
+	config.Application.LogFormat = c.Application.LogFormat
+	config.Application.LogLevel = c.Application.LogLevel
+	config.Generation.UseExamples = c.Generation.UseExamples
+	config.Generation.SuppressErrors = c.Generation.SuppressErrors
+	config.Generation.NullProbability = c.Generation.NullProbability
+	config.Generation.DefaultMinInt = c.Generation.DefaultMinInt
+	config.Generation.DefaultMaxInt = c.Generation.DefaultMaxInt
+	config.Generation.DefaultMinFloat = c.Generation.DefaultMinFloat
+	config.Generation.DefaultMaxFloat = c.Generation.DefaultMaxFloat
+
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("unable to encode openapi-mock config: %w", err)
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteMockingConfig writes the default mocking config
+func WriteMockingConfig(w io.Writer) error {
+	return DefaultConfig().Write(w)
+}
+
+// LoadConfig reads the mocking config file over the default config. Files written by older versions
+// of kusk are migrated: the settings they set are kept and the file is written again with the current version.
+// It returns whether the file was migrated.
+func LoadConfig(path string) (Config, bool, error) {
+	config := DefaultConfig()
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, false, writeConfig(path, config)
+	}
+	if err != nil {
+		return Config{}, false, fmt.Errorf("unable to read mocking config %s: %w", path, err)
+	}
+
+	// the version of the file is only known once it's read
+	config.Version = 0
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return Config{}, false, fmt.Errorf("unable to parse mocking config %s: %w", path, err)
+	}
+
+	if config.Version > ConfigVersion {
+		return Config{}, false, fmt.Errorf("mocking config %s has version %d which is newer than this kusk supports, upgrade kusk", path, config.Version)
+	}
+
+	if config.Version == ConfigVersion {
+		return config, false, nil
+	}
+
+	config.Version = ConfigVersion
+	if err := config.Validate(); err != nil {
+		return Config{}, false, fmt.Errorf("unable to migrate mocking config %s: %w", path, err)
+	}
+
+	return config, true, writeConfig(path, config)
+}
+
+func writeConfig(path string, config Config) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create mocking config file at %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := config.Write(f); err != nil {
+		return fmt.Errorf("unable to write mocking config to %s: %w", path, err)
+	}
+
+	return f.Close()
+}
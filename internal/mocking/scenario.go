@@ -0,0 +1,146 @@
+package mocking
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// Scenario scripts the responses the mock server plays back for operations, e.g.
+//
+//	operations:
+//	  getTodos:
+//	    steps:
+//	      - status: 503
+//	        delay: 2s
+//	      - reset: true
+//	      - example: happyPath
+//	        delay: 100ms-500ms
+type Scenario struct {
+	// Operations are keyed by operationId or by method and path, e.g. "GET /todos"
+	Operations map[string]OperationScenario `json:"operations"`
+}
+
+// OperationScenario is the ordered list of responses served for an operation
+type OperationScenario struct {
+	Steps []ScenarioStep `json:"steps"`
+	// Loop starts over from the first step once all steps were played,
+	// otherwise the last step keeps being repeated
+	Loop bool `json:"loop,omitempty"`
+}
+
+// ScenarioStep describes a single response of an operation scenario
+type ScenarioStep struct {
+	// Status is the status code to respond with, the operation's default success status when empty
+	Status int `json:"status,omitempty"`
+	// Example is the name of the example of the response to serve
+	Example string `json:"example,omitempty"`
+	// Delay is a fixed latency such as 500ms or a random one within a range such as 100ms-2s
+	Delay string `json:"delay,omitempty"`
+	// Reset closes the connection without responding
+	Reset bool `json:"reset,omitempty"`
+}
+
+// LoadScenario reads and validates a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read scenario file %s: %w", path, err)
+	}
+
+	var scenario Scenario
+	if err := yaml.Unmarshal(b, &scenario); err != nil {
+		return nil, fmt.Errorf("unable to parse scenario file %s: %w", path, err)
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %w", path, err)
+	}
+
+	return &scenario, nil
+}
+
+func (s Scenario) Validate() error {
+	for operation, operationScenario := range s.Operations {
+		if len(operationScenario.Steps) == 0 {
+			return fmt.Errorf("operations.%s: at least one step is required", operation)
+		}
+
+		for i, step := range operationScenario.Steps {
+			if err := step.Validate(); err != nil {
+				return fmt.Errorf("operations.%s.steps.%d: %w", operation, i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s ScenarioStep) Validate() error {
+	if s.Status != 0 && (s.Status < 100 || s.Status > 599) {
+		return fmt.Errorf("invalid status code %d", s.Status)
+	}
+
+	if s.Reset && (s.Status != 0 || s.Example != "") {
+		return errors.New("reset steps can't define a status or example as nothing is sent")
+	}
+
+	if _, _, err := s.DelayRange(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DelayRange parses the delay of the step into the range the latency is picked from
+func (s ScenarioStep) DelayRange() (time.Duration, time.Duration, error) {
+	if s.Delay == "" {
+		return 0, 0, nil
+	}
+
+	bounds := strings.SplitN(s.Delay, "-", 2)
+
+	min, err := time.ParseDuration(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delay %q: %w", s.Delay, err)
+	}
+
+	max := min
+	if len(bounds) == 2 {
+		if max, err = time.ParseDuration(strings.TrimSpace(bounds[1])); err != nil {
+			return 0, 0, fmt.Errorf("invalid delay %q: %w", s.Delay, err)
+		}
+	}
+
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid delay %q: the range must be positive and increasing", s.Delay)
+	}
+
+	return min, max, nil
+}
+
+// String describes the step for the access log
+func (s ScenarioStep) String() string {
+	var parts []string
+	if s.Reset {
+		parts = append(parts, "connection reset")
+	}
+	if s.Status != 0 {
+		parts = append(parts, fmt.Sprintf("status %d", s.Status))
+	}
+	if s.Example != "" {
+		parts = append(parts, "example "+s.Example)
+	}
+	if s.Delay != "" {
+		parts = append(parts, "delay "+s.Delay)
+	}
+	if len(parts) == 0 {
+		return "default response"
+	}
+
+	return strings.Join(parts, ", ")
+}
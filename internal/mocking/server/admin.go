@@ -0,0 +1,405 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+// AdminPathPrefix is the path the admin API is served under, next to the mocked APIs
+const AdminPathPrefix = "/__kusk"
+
+// DefaultJournalSize is how many of the latest requests served the admin API lists unless set otherwise
+const DefaultJournalSize = 1000
+
+// credentialHeaders are recorded redacted, along with the headers of the apiKey security schemes of the spec,
+// as the admin API lists them to anyone reaching the mock
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+const redactedHeader = "<redacted>"
+
+// recordedRequest is a request served by the mock server as listed by the admin API
+type recordedRequest struct {
+	TimeStamp time.Time       `json:"timestamp"`
+	Spec      string          `json:"spec,omitempty"`
+	Operation string          `json:"operation,omitempty"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Query     string          `json:"query,omitempty"`
+	Headers   http.Header     `json:"headers,omitempty"`
+	Body      json.RawMessage `json:"body,omitempty"`
+	// BodyEncoding is base64 for binary bodies
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+	Status       int    `json:"status"`
+}
+
+// requestJournal keeps the latest requests served by the mock server, forgetting the oldest one once size are kept
+type requestJournal struct {
+	mu   sync.Mutex
+	size int
+	// requests is a ring buffer, next is where the next request is kept once it's full
+	requests []recordedRequest
+	next     int
+}
+
+func newRequestJournal(size int) *requestJournal {
+	return &requestJournal{size: size}
+}
+
+func (j *requestJournal) add(request recordedRequest) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.requests) < j.size {
+		j.requests = append(j.requests, request)
+		return
+	}
+
+	j.requests[j.next] = request
+	j.next = (j.next + 1) % j.size
+}
+
+// list returns the requests matching all the non empty filters, operations is nil when requests aren't filtered by operation
+func (j *requestJournal) list(operations []operationRef, method, path string) []recordedRequest {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	requests := []recordedRequest{}
+	for i := range j.requests {
+		// oldest first
+		request := j.requests[(j.next+i)%len(j.requests)]
+		if (operations == nil || servedBy(request, operations)) &&
+			(method == "" || strings.EqualFold(request.Method, method)) &&
+			(path == "" || request.Path == path) {
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+// servedBy tells whether the request was served by one of the operations
+func servedBy(request recordedRequest, operations []operationRef) bool {
+	for _, operation := range operations {
+		if request.Spec == operation.spec && request.Operation == operation.key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (j *requestJournal) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.requests = nil
+	j.next = 0
+}
+
+// redactHeaders returns the headers with the values of the credential headers and the headers
+// of the apiKey security schemes of the spec redacted
+func redactHeaders(headers http.Header, spec *openapi3.T) http.Header {
+	names := credentialHeaders
+	for _, scheme := range spec.Components.SecuritySchemes {
+		if scheme.Value != nil && scheme.Value.Type == "apiKey" && scheme.Value.In == "header" {
+			names = append(names, scheme.Value.Name)
+		}
+	}
+
+	redacted := headers.Clone()
+	for _, name := range names {
+		values := redacted.Values(name)
+		for i := range values {
+			values[i] = redactedHeader
+		}
+	}
+
+	return redacted
+}
+
+// activeExamples holds the examples switched to at runtime through the admin API, by the scoped key of their operation
+type activeExamples struct {
+	mu       sync.Mutex
+	examples map[string]mocking.ScenarioStep
+}
+
+func newActiveExamples() *activeExamples {
+	return &activeExamples{examples: map[string]mocking.ScenarioStep{}}
+}
+
+// get returns the step serving the active example of the operation, nil when none was set
+func (a *activeExamples) get(operation string) *mocking.ScenarioStep {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	step, ok := a.examples[operation]
+	if !ok {
+		return nil
+	}
+
+	return &step
+}
+
+func (a *activeExamples) set(operation string, step mocking.ScenarioStep) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.examples[operation] = step
+}
+
+func (a *activeExamples) unset(operation string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.examples, operation)
+}
+
+func (a *activeExamples) list() map[string]mocking.ScenarioStep {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	examples := make(map[string]mocking.ScenarioStep, len(a.examples))
+	for operation, step := range a.examples {
+		examples[operation] = step
+	}
+
+	return examples
+}
+
+func (a *activeExamples) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.examples = map[string]mocking.ScenarioStep{}
+}
+
+// adminHandler serves the admin API, letting tests inspect the traffic the mock server received
+// and change what it serves at runtime:
+//
+//	GET    /__kusk/requests?operation=&method=&path=  lists the requests served
+//	DELETE /__kusk/requests                           forgets the requests served
+//	POST   /__kusk/reset                              resets requests, stored resources, scenarios and active examples
+//	GET    /__kusk/examples                           lists the active examples
+//	PUT    /__kusk/examples/{operation}               switches the example served for an operation, e.g. {"example": "notFound"}
+//	DELETE /__kusk/examples/{operation}               goes back to the default response of the operation
+//
+// Operations are given by operationId or by method and path, prefixed with the name of the spec
+// such as todos:GET /todos when several specs are served and more than one has the operation.
+type adminHandler struct {
+	server     *NativeMockServer
+	operations operationIndex
+}
+
+func (a *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, AdminPathPrefix), "/")
+
+	switch {
+	case path == "/requests" && r.Method == http.MethodGet:
+		query := r.URL.Query()
+		var operations []operationRef
+		if key := query.Get("operation"); key != "" {
+			if operations = a.operations.lookup(key); len(operations) == 0 {
+				writeError(w, errorResponse{Status: http.StatusNotFound, Message: a.operations.notFound(key)})
+				return
+			}
+		}
+		requests := a.server.journal.list(operations, query.Get("method"), query.Get("path"))
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":    len(requests),
+			"requests": requests,
+		})
+	case path == "/requests" && r.Method == http.MethodDelete:
+		a.server.journal.reset()
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/reset" && r.Method == http.MethodPost:
+		a.server.reset()
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/examples" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.server.examples.list())
+	case strings.HasPrefix(path, "/examples/") && r.Method == http.MethodPut:
+		a.setExample(w, r, strings.TrimPrefix(path, "/examples/"))
+	case strings.HasPrefix(path, "/examples/") && r.Method == http.MethodDelete:
+		operation, err := a.operations.find(strings.TrimPrefix(path, "/examples/"))
+		if err != nil {
+			writeError(w, errorResponse{Status: http.StatusNotFound, Message: err.Error()})
+			return
+		}
+		a.server.examples.unset(operation.scopedKey())
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, errorResponse{Status: http.StatusNotFound, Message: fmt.Sprintf("unknown admin endpoint %s %s", r.Method, r.URL.Path)})
+	}
+}
+
+// setExample switches the example served for the operation, which is found in any response of the operation
+// unless the status to serve is given as well
+func (a *adminHandler) setExample(w http.ResponseWriter, r *http.Request, key string) {
+	operation, err := a.operations.find(key)
+	if err != nil {
+		writeError(w, errorResponse{Status: http.StatusNotFound, Message: err.Error()})
+		return
+	}
+
+	var step mocking.ScenarioStep
+	if err := json.NewDecoder(r.Body).Decode(&step); err != nil || step.Example == "" {
+		writeError(w, errorResponse{Status: http.StatusBadRequest, Message: `expected a body such as {"example": "notFound"} with an optional "status"`})
+		return
+	}
+
+	status, ok := findExample(operation.operation, step.Example, step.Status)
+	if !ok {
+		writeError(w, errorResponse{Status: http.StatusBadRequest, Message: fmt.Sprintf("example %s not found in the responses of %s", step.Example, key)})
+		return
+	}
+
+	step = mocking.ScenarioStep{Status: status, Example: step.Example}
+	// examples are kept under the key the handler finds the operation of a request by
+	a.server.examples.set(operation.scopedKey(), step)
+	writeJSON(w, http.StatusOK, step)
+}
+
+// findExample returns the status of the response of the operation defining the named example,
+// only looking at the response for the given status when it's set
+func findExample(operation *openapi3.Operation, name string, status int) (int, bool) {
+	if status != 0 {
+		_, _, ok := namedExample(responseForStatus(operation, status), "", name)
+		return status, ok
+	}
+
+	keys := make([]string, 0, len(operation.Responses))
+	for key := range operation.Responses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		status, ok := parseStatusCode(key)
+		if !ok {
+			status = http.StatusOK
+		}
+
+		if _, _, ok := namedExample(operation.Responses[key].Value, "", name); ok {
+			return status, true
+		}
+	}
+
+	return 0, false
+}
+
+// operationRef is an operation of a served spec
+type operationRef struct {
+	// spec is the name of the spec serving the operation, only set when several specs are served
+	spec string
+	// key is the operationId of the operation, or its method and path when it has none, as operationKey returns
+	key       string
+	operation *openapi3.Operation
+}
+
+// scopedKey identifies the operation across the served specs
+func (o operationRef) scopedKey() string {
+	return scopedOperationKey(o.spec, o.key)
+}
+
+// scopedOperationKey prefixes the key of an operation with the name of its spec, when it's set
+func scopedOperationKey(spec, key string) string {
+	if spec == "" {
+		return key
+	}
+
+	return spec + ":" + key
+}
+
+// operationIndex finds the operations of the served specs by operationId and by method and path,
+// with or without the name of their spec as prefix
+type operationIndex map[string][]operationRef
+
+// newOperationIndex indexes the operations of the specs, which are scoped by the name of their spec when there's more than one
+func newOperationIndex(specs []mocking.Spec) operationIndex {
+	index := operationIndex{}
+	for _, s := range specs {
+		name := ""
+		if len(specs) > 1 {
+			name = s.Name
+		}
+
+		for path, pathItem := range s.API.Paths {
+			for method, operation := range pathItem.Operations() {
+				ref := operationRef{spec: name, key: method + " " + path, operation: operation}
+				keys := []string{method + " " + path}
+				if operation.OperationID != "" {
+					ref.key = operation.OperationID
+					keys = append(keys, operation.OperationID)
+				}
+
+				for _, key := range keys {
+					index[key] = append(index[key], ref)
+					if name != "" {
+						index[scopedOperationKey(name, key)] = append(index[scopedOperationKey(name, key)], ref)
+					}
+				}
+			}
+		}
+	}
+
+	return index
+}
+
+// lookup returns the operations the key refers to, more than one when several specs have the operation
+func (i operationIndex) lookup(key string) []operationRef {
+	return i[key]
+}
+
+// find returns the only operation the key refers to
+func (i operationIndex) find(key string) (operationRef, error) {
+	refs := i.lookup(key)
+	switch len(refs) {
+	case 0:
+		return operationRef{}, errors.New(i.notFound(key))
+	case 1:
+		return refs[0], nil
+	}
+
+	scoped := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		scoped = append(scoped, ref.scopedKey())
+	}
+	sort.Strings(scoped)
+
+	return operationRef{}, fmt.Errorf("operation %s is served by several specs, use one of %s", key, strings.Join(scoped, ", "))
+}
+
+func (i operationIndex) notFound(key string) string {
+	return fmt.Sprintf("operation %s not found, use an operationId or a method and path such as \"GET /todos\"", key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	b, _ := json.MarshalIndent(body, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// adminMux serves the admin API next to the mocked APIs
+type adminMux struct {
+	admin http.Handler
+	mock  http.Handler
+}
+
+func (m adminMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == AdminPathPrefix || strings.HasPrefix(r.URL.Path, AdminPathPrefix+"/") {
+		m.admin.ServeHTTP(w, r)
+		return
+	}
+
+	m.mock.ServeHTTP(w, r)
+}
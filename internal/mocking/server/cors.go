@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// CORS policies of the native mock server
+const (
+	// CORSSpec applies the x-kusk cors options of the operations like kusk gateway does,
+	// allowing any origin for operations without cors options
+	CORSSpec = "spec"
+	// CORSPermissive allows any origin, method and header for all operations regardless of the spec
+	CORSPermissive = "permissive"
+	// CORSOff doesn't answer preflight requests nor add CORS headers
+	CORSOff = "off"
+)
+
+// permissiveCORS is applied to operations without x-kusk cors options, so browser apps can call the mock from anywhere
+var permissiveCORS = options.CORSOptions{
+	Origins:       []string{"*"},
+	Methods:       []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"},
+	Headers:       []string{"*"},
+	ExposeHeaders: []string{"*"},
+}
+
+// isPreflight tells whether the request is a CORS preflight request rather than a call to an OPTIONS operation
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// routingRequest returns the request to route, which for preflight requests is the request the browser is about to make
+func routingRequest(r *http.Request) *http.Request {
+	if !isPreflight(r) {
+		return r
+	}
+
+	routed := r.Clone(r.Context())
+	routed.Method = strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	return routed
+}
+
+// corsOptions returns the CORS options applying to the operation of the route, nil when CORS is off
+func (h *mockHandler) corsOptions(route *routers.Route) *options.CORSOptions {
+	switch h.cors {
+	case CORSOff:
+		return nil
+	case CORSPermissive:
+		return &permissiveCORS
+	}
+
+	if cors, ok := h.router.cors[route.Method+route.Path]; ok {
+		return cors
+	}
+
+	return &permissiveCORS
+}
+
+// setCORSHeaders adds the CORS headers allowing the origin of the request to read the response
+func (h *mockHandler) setCORSHeaders(w http.ResponseWriter, r *http.Request, route *routers.Route) {
+	origin := r.Header.Get("Origin")
+	cors := h.corsOptions(route)
+	if origin == "" || cors == nil || !originAllowed(cors, origin) {
+		return
+	}
+
+	allowCredentials := cors.Credentials != nil && *cors.Credentials
+	if contains(cors.Origins, "*") && !allowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+	}
+}
+
+// servePreflight answers the preflight request for the operation of the route
+func (h *mockHandler) servePreflight(w http.ResponseWriter, r *http.Request, route *routers.Route) error {
+	cors := h.corsOptions(route)
+	if cors == nil {
+		err := errors.New("CORS is off, preflight requests aren't answered")
+		writeError(w, errorResponse{Status: http.StatusMethodNotAllowed, Message: err.Error()})
+		return err
+	}
+
+	origin := r.Header.Get("Origin")
+	if !originAllowed(cors, origin) {
+		err := fmt.Errorf("origin %s is not allowed by the x-kusk cors options of %s %s", origin, route.Method, route.Path)
+		writeError(w, errorResponse{Status: http.StatusForbidden, Message: err.Error()})
+		return err
+	}
+
+	h.setCORSHeaders(w, r, route)
+	if len(cors.Methods) > 0 {
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.Methods, ", "))
+	}
+	if len(cors.Headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.Headers, ", "))
+	}
+	if cors.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func originAllowed(cors *options.CORSOptions, origin string) bool {
+	return contains(cors.Origins, "*") || contains(cors.Origins, origin)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+// hopHeaders aren't recorded as they only apply to the connection to the upstream
+var hopHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Date",
+	"Keep-Alive",
+	"Transfer-Encoding",
+}
+
+// operationKey identifies the operation of the route in fixtures by its operationId, or its method and path
+func operationKey(route *routers.Route) string {
+	if route.Operation != nil && route.Operation.OperationID != "" {
+		return route.Operation.OperationID
+	}
+
+	return route.Method + " " + route.Path
+}
+
+// fixtureSet serves back recorded fixtures for the requests they were recorded for
+type fixtureSet map[string][]mocking.Fixture
+
+func newFixtureSet(fixtures []mocking.Fixture) fixtureSet {
+	set := fixtureSet{}
+	for _, fixture := range fixtures {
+		set[fixture.Operation] = append(set[fixture.Operation], fixture)
+	}
+
+	return set
+}
+
+// find returns the fixture recorded for the same path, preferring one with the same query as well
+func (s fixtureSet) find(route *routers.Route, r *http.Request) *mocking.Fixture {
+	candidates := s[operationKey(route)]
+
+	var match *mocking.Fixture
+	for i, fixture := range candidates {
+		if fixture.Request.Method != r.Method || fixture.Request.Path != r.URL.Path {
+			continue
+		}
+
+		if fixture.Request.Query == r.URL.RawQuery {
+			return &candidates[i]
+		}
+
+		if match == nil {
+			match = &candidates[i]
+		}
+	}
+
+	return match
+}
+
+// writeFixture serves the recorded response of the fixture
+func writeFixture(w http.ResponseWriter, fixture *mocking.Fixture) error {
+	for name, values := range fixture.Response.Headers {
+		w.Header()[name] = values
+	}
+
+	body, err := decodeFixtureBody(w.Header().Get("Content-Type"), fixture.Response.BodyEncoding, fixture.Response.Body)
+	if err != nil {
+		err = fmt.Errorf("invalid body in fixture %s: %w", fixture.FileName(), err)
+		writeError(w, errorResponse{Status: http.StatusInternalServerError, Message: err.Error()})
+		return err
+	}
+
+	w.WriteHeader(fixture.Response.Status)
+	w.Write(body)
+
+	return nil
+}
+
+// encodeFixtureBody keeps JSON bodies readable in fixtures, stores any other text as a JSON string
+// and binary bodies, which don't survive as a JSON string, base64 encoded.
+// It returns the encoding of the body, empty unless base64 encoded.
+func encodeFixtureBody(contentType string, body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if isJSON(mediaType) && json.Valid(body) {
+		return body, ""
+	}
+
+	if !utf8.Valid(body) {
+		b, _ := json.Marshal(base64.StdEncoding.EncodeToString(body))
+		return b, mocking.BodyEncodingBase64
+	}
+
+	b, _ := json.Marshal(string(body))
+	return b, ""
+}
+
+func decodeFixtureBody(contentType, encoding string, body json.RawMessage) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	switch encoding {
+	case "":
+	case mocking.BodyEncodingBase64:
+		var s string
+		if err := json.Unmarshal(body, &s); err != nil {
+			return nil, err
+		}
+
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown body encoding %q", encoding)
+	}
+
+	// undo the indentation of the fixture file
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if isJSON(mediaType) {
+		var buf bytes.Buffer
+		err := json.Compact(&buf, body)
+		return buf.Bytes(), err
+	}
+
+	var s string
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, err
+	}
+
+	return []byte(s), nil
+}
+
+// recorder proxies requests to the upstream and records the responses as fixtures
+type recorder struct {
+	proxy *httputil.ReverseProxy
+	dir   string
+}
+
+type recordingKey struct{}
+
+// recording is passed to the proxy callbacks through the request context to report back how the request went
+type recording struct {
+	route   *routers.Route
+	request mocking.FixtureRequest
+	err     error
+}
+
+func newRecorder(upstream *url.URL, dir string) *recorder {
+	rec := &recorder{dir: dir}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = upstream.Host
+		// ask for uncompressed responses so fixtures stay readable
+		r.Header.Del("Accept-Encoding")
+	}
+	proxy.ModifyResponse = rec.record
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		r.Context().Value(recordingKey{}).(*recording).err = fmt.Errorf("unable to proxy request to %s: %w", upstream, err)
+		writeError(w, errorResponse{Status: http.StatusBadGateway, Message: err.Error()})
+	}
+	rec.proxy = proxy
+
+	return rec
+}
+
+// serve proxies the request to the upstream, recording the response for the operation of the route
+func (rec *recorder) serve(w http.ResponseWriter, r *http.Request, route *routers.Route) error {
+	recording := &recording{
+		route: route,
+		// keep the path as requested from the mock server, the upstream may be served under a base path
+		request: mocking.FixtureRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+		},
+	}
+	rec.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordingKey{}, recording)))
+
+	return recording.err
+}
+
+// record saves the upstream response as a fixture. Failing to do so is reported
+// in the access log rather than failing the proxied request.
+func (rec *recorder) record(resp *http.Response) error {
+	recording := resp.Request.Context().Value(recordingKey{}).(*recording)
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		recording.err = fmt.Errorf("unable to read upstream response: %w", err)
+		return nil
+	}
+
+	headers := resp.Header.Clone()
+	for _, name := range hopHeaders {
+		headers.Del(name)
+	}
+
+	encodedBody, encoding := encodeFixtureBody(resp.Header.Get("Content-Type"), body)
+	fixture := mocking.Fixture{
+		Operation: operationKey(recording.route),
+		Request:   recording.request,
+		Response: mocking.FixtureResponse{
+			Status:       resp.StatusCode,
+			Headers:      headers,
+			Body:         encodedBody,
+			BodyEncoding: encoding,
+		},
+	}
+
+	if _, err := mocking.SaveFixture(rec.dir, fixture); err != nil {
+		recording.err = err
+	}
+
+	return nil
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+	"github.com/kubeshop/kusk/internal/mocking/generator"
+)
+
+var errConnectionReset = errors.New("connection reset by scenario")
+
+// mockHandler serves the mocked responses for the operations of a spec
+type mockHandler struct {
+	// name is the name of the spec served, only set when serving several specs
+	name             string
+	router           *router
+	cors             string
+	generation       mocking.GenerationConfig
+	validateRequests bool
+	logCh            chan<- mocking.AccessLogEntry
+
+	// tokens are the credentials accepted per security scheme when enforcing security, any value is accepted for the other schemes
+	enforceSecurity bool
+	tokens          map[string][]string
+
+	// store is only set when serving statefully
+	store     *resourceStore
+	resources map[string]resource
+
+	// scenario is only set when playing back a scenario
+	scenario *scenarioPlayer
+
+	// recorder is only set when recording fixtures from an upstream, fixtures only when replaying them
+	recorder *recorder
+	fixtures fixtureSet
+
+	// journal and examples are only set when serving the admin API
+	journal  *requestJournal
+	examples *activeExamples
+}
+
+func (h *mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	entry := mocking.AccessLogEntry{
+		TimeStamp: time.Now(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+	}
+
+	var body []byte
+	if h.journal != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// log from a deferred call as resetting connections can abort the handler
+	defer func() {
+		entry.Latency = time.Since(entry.TimeStamp)
+		entry.StatusCode = strconv.Itoa(sw.status)
+		if errors.Is(entry.Error, errConnectionReset) {
+			entry.StatusCode = "-"
+		}
+
+		if h.journal != nil {
+			encodedBody, encoding := encodeFixtureBody(r.Header.Get("Content-Type"), body)
+			h.journal.add(recordedRequest{
+				TimeStamp:    entry.TimeStamp,
+				Spec:         entry.Spec,
+				Operation:    entry.Operation,
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				Query:        r.URL.RawQuery,
+				Headers:      redactHeaders(r.Header, h.router.spec),
+				Body:         encodedBody,
+				BodyEncoding: encoding,
+				Status:       sw.status,
+			})
+		}
+
+		// never wait for the access log to be read, a reader busy elsewhere, e.g. shutting the server down,
+		// would otherwise keep the request from finishing
+		select {
+		case h.logCh <- entry:
+		default:
+		}
+	}()
+
+	entry.Error = h.serve(sw, r, &entry)
+}
+
+// serve writes the mocked response for the request and describes how it was produced in the access log entry.
+// The returned error explains why the request couldn't be served successfully.
+func (h *mockHandler) serve(w http.ResponseWriter, r *http.Request, entry *mocking.AccessLogEntry) error {
+	route, pathParams, err := h.router.findRoute(routingRequest(r))
+	if err == nil || errors.Is(err, routers.ErrMethodNotAllowed) {
+		entry.Spec = h.name
+	}
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, routers.ErrMethodNotAllowed) {
+			status = http.StatusMethodNotAllowed
+		}
+		writeError(w, errorResponse{Status: status, Message: err.Error()})
+		return err
+	}
+	entry.Operation = operationKey(route)
+
+	// preflight requests are answered by the gateway before reaching the upstream, mocked or not
+	if isPreflight(r) {
+		return h.servePreflight(w, r, route)
+	}
+	h.setCORSHeaders(w, r, route)
+
+	if route.Operation == nil {
+		err := fmt.Errorf("mocking is not enabled for %s %s in x-kusk, kusk gateway forwards it to the upstream", r.Method, route.Path)
+		writeError(w, errorResponse{Status: http.StatusNotImplemented, Message: err.Error()})
+		return err
+	}
+
+	if h.enforceSecurity {
+		if err := checkSecurity(r, route, h.tokens); err != nil {
+			for _, challenge := range err.challenges {
+				w.Header().Add("WWW-Authenticate", challenge)
+			}
+			writeError(w, errorResponse{Status: err.status, Message: err.Error()})
+			return err
+		}
+	}
+
+	if h.validateRequests {
+		if validationErrors := validateRequest(r, route, pathParams); len(validationErrors) > 0 {
+			writeError(w, errorResponse{
+				Status:  http.StatusBadRequest,
+				Message: "request doesn't match the OpenAPI spec",
+				Errors:  validationErrors,
+			})
+			return fmt.Errorf("request validation failed: %s", validationErrorsMessage(validationErrors))
+		}
+	}
+
+	if h.recorder != nil {
+		entry.Source = mocking.SourceUpstream
+		return h.recorder.serve(w, r, route)
+	}
+
+	status, response := selectResponse(route.Operation)
+
+	step, description := h.nextStep(route)
+	if step != nil {
+		entry.Scenario = description
+
+		if err := delay(r, *step); err != nil {
+			return err
+		}
+
+		if step.Reset {
+			resetConnection(w)
+			return errConnectionReset
+		}
+
+		if step.Status != 0 {
+			status, response = step.Status, responseForStatus(route.Operation, step.Status)
+		}
+	}
+
+	// scripted responses are served as is rather than going through fixtures or the store
+	scripted := step != nil && (step.Status != 0 || step.Example != "")
+
+	if h.fixtures != nil && !scripted {
+		if fixture := h.fixtures.find(route, r); fixture != nil {
+			entry.Source = mocking.SourceFixture
+			return writeFixture(w, fixture)
+		}
+	}
+
+	gen := generator.New(h.randSource(route, pathParams, r))
+	gen.Options = h.generation.GeneratorOptions()
+
+	for name, values := range responseHeaders(response, gen) {
+		w.Header()[name] = values
+	}
+
+	var body interface{}
+	contentType, mediaType := negotiateContent(response, r.Header.Get("Accept"))
+	switch {
+	case step != nil && step.Example != "":
+		var ok bool
+		if contentType, body, ok = namedExample(response, contentType, step.Example); !ok {
+			err := fmt.Errorf("example %s not found for status %d of %s %s", step.Example, status, r.Method, route.Path)
+			writeError(w, errorResponse{Status: http.StatusInternalServerError, Message: err.Error()})
+			return err
+		}
+		mediaType = response.Content[contentType]
+		entry.Source = mocking.SourceExample
+	case mediaType != nil:
+		if body, entry.Source, err = h.responseBody(mediaType, gen); err != nil {
+			return h.generationError(w, status, err)
+		}
+	}
+
+	if res, ok := h.resources[route.Path]; ok && h.store != nil && !scripted {
+		if status, body, err = h.store.apply(r, res, pathParams, status, body); err != nil {
+			writeError(w, errorResponse{Status: status, Message: err.Error()})
+			return err
+		}
+	}
+
+	if mediaType == nil {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	b, err := encodeBody(contentType, body)
+	if err != nil {
+		return h.generationError(w, status, fmt.Errorf("unable to encode response: %w", err))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(b)
+
+	return nil
+}
+
+// generationError fails the request when its response couldn't be generated. With suppress_errors the status
+// of the response is served without a body instead, the error is still logged.
+func (h *mockHandler) generationError(w http.ResponseWriter, status int, err error) error {
+	if h.generation.SuppressErrors {
+		w.WriteHeader(status)
+		return err
+	}
+
+	writeError(w, errorResponse{Status: http.StatusInternalServerError, Message: err.Error()})
+	return err
+}
+
+// responseBody applies the use_examples policy from the mocking config when choosing a response body,
+// and tells whether the body is an example or was generated from the schema
+func (h *mockHandler) responseBody(mediaType *openapi3.MediaType, gen *generator.Generator) (interface{}, string, error) {
+	switch h.generation.UseExamples {
+	case mocking.UseExamplesNo:
+		if mediaType.Schema == nil || mediaType.Schema.Value == nil {
+			return nil, "", nil
+		}
+		gen.IgnoreExamples = true
+		return gen.Generate(mediaType.Schema.Value), mocking.SourceSchema, nil
+	case mocking.UseExamplesExclusively:
+		body, fromExample := responseBody(mediaType, gen)
+		if !fromExample {
+			return nil, "", errors.New("no example defined for response and use_examples is set to exclusively")
+		}
+		return body, mocking.SourceExample, nil
+	}
+
+	body, fromExample := responseBody(mediaType, gen)
+	switch {
+	case fromExample:
+		return body, mocking.SourceExample, nil
+	case body == nil:
+		return nil, "", nil
+	}
+	return body, mocking.SourceSchema, nil
+}
+
+// randSource returns the source of the random data generated for the response. With a seed the source only depends
+// on the seed, operation and parameters of the request, so the same request is always served the same response.
+func (h *mockHandler) randSource(route *routers.Route, pathParams map[string]string, r *http.Request) rand.Source {
+	if h.generation.Seed == nil {
+		return rand.NewSource(time.Now().UnixNano())
+	}
+
+	names := make([]string, 0, len(pathParams))
+	for name := range pathParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d\n%s %s\n", *h.generation.Seed, route.Method, route.Path)
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%s\n", name, pathParams[name])
+	}
+	// Encode sorts the query parameters so their order doesn't matter
+	fmt.Fprintln(hash, r.URL.Query().Encode())
+
+	return rand.NewSource(int64(hash.Sum64()))
+}
+
+// nextStep returns the scenario step to play for the route, falling back to the example switched to
+// through the admin API. The step is nil when the default response should be served.
+func (h *mockHandler) nextStep(route *routers.Route) (*mocking.ScenarioStep, string) {
+	if h.scenario != nil {
+		if step, description := h.scenario.next(route); step != nil {
+			return step, description
+		}
+	}
+
+	if h.examples != nil {
+		if step := h.examples.get(scopedOperationKey(h.name, operationKey(route))); step != nil {
+			return step, "active example " + step.Example
+		}
+	}
+
+	return nil, ""
+}
+
+// delay waits for the latency of the scenario step, unless the client goes away first
+func delay(r *http.Request, step mocking.ScenarioStep) error {
+	min, max, err := step.DelayRange()
+	if err != nil || max == 0 {
+		return err
+	}
+
+	latency := min
+	if max > min {
+		latency += time.Duration(rand.Int63n(int64(max - min)))
+	}
+
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
+// resetConnection closes the client connection without sending a response
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	// discard unsent data so the client receives a RST rather than a graceful close
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// specMux serves several specs on the same port, handing each request to the handler of the spec it's for
+type specMux []*mockHandler
+
+func (m specMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// prefer the spec defining the operation, then one defining the path so the method is reported as not allowed.
+	// Requests for paths none of the specs define are reported as not found by the first spec
+	target := m[0]
+	var pathMatched bool
+	for _, h := range m {
+		_, _, err := h.router.findRoute(routingRequest(r))
+		if err == nil {
+			target = h
+			break
+		}
+		if errors.Is(err, routers.ErrMethodNotAllowed) && !pathMatched {
+			target, pathMatched = h, true
+		}
+	}
+
+	target.ServeHTTP(w, r)
+}
+
+// errorResponse is the body served when the mock server can't serve a mocked response
+type errorResponse struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Errors  []validationError `json:"errors,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, resp errorResponse) {
+	b, _ := encodeBody("application/json", resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	w.Write(b)
+}
+
+// statusWriter records the status code written so it can be reported in the access log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer doesn't support hijacking")
+	}
+
+	return hijacker.Hijack()
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+var _ mocking.Backend = (*NativeMockServer)(nil)
+
+// accessLogBuffer is how many access log entries are kept until read, entries beyond it are dropped
+const accessLogBuffer = 1024
+
+// NativeMockServer is a mocking.Backend serving mocked responses for an OpenAPI spec
+// from within the kusk process, so no container runtime is needed
+type NativeMockServer struct {
+	specs      []mocking.Spec
+	port       uint32
+	generation mocking.GenerationConfig
+	tokens     map[string][]string
+	options    NativeOptions
+	server     *http.Server
+	// handler serves the current specs and is swapped on reload
+	handler *swappableHandler
+	// stores are kept per spec name across reloads so changing a spec doesn't lose the stored resources
+	stores map[string]*resourceStore
+	// scenario is kept across reloads so the scenario continues where it left off
+	scenario *scenarioPlayer
+	// journal and examples are only set when serving the admin API
+	journal  *requestJournal
+	examples *activeExamples
+
+	logCh chan mocking.AccessLogEntry
+	errCh chan error
+}
+
+// NativeOptions configure the optional behaviour of the native mock server
+type NativeOptions struct {
+	// ValidateRequests rejects requests that don't match the parameters and request body of their operation
+	ValidateRequests bool
+	// Stateful keeps the resources written with POST, PUT, PATCH and DELETE requests in memory
+	// and serves them back on GET requests
+	Stateful bool
+	// Scenario plays back scripted responses for the operations it lists
+	Scenario *mocking.Scenario
+	// Upstream is the real service requests are proxied to when recording fixtures into RecordDir
+	Upstream  *url.URL
+	RecordDir string
+	// Seed makes generated responses stable for the same operation and parameters,
+	// overriding the seed of the mocking config
+	Seed *int64
+	// Admin serves the admin API under AdminPathPrefix to inspect the requests served and change the examples served
+	Admin bool
+	// JournalSize is how many of the latest requests served the admin API lists, DefaultJournalSize when not set
+	JournalSize int
+	// Fixtures are served back for the requests they were recorded for, other requests are mocked as usual
+	Fixtures []mocking.Fixture
+	// EnforceSecurity rejects requests without the credentials required by the security requirements of their operation,
+	// accepting the tokens listed per security scheme in the mocking config
+	EnforceSecurity bool
+	// CORS is the CORS policy applied, CORSSpec when not set
+	CORS string
+	// Address is the address to listen on, 127.0.0.1 when not set so the mock isn't reachable from other hosts
+	Address string
+	// Certificate serves the APIs over HTTPS, negotiating HTTP/2 with clients supporting it
+	Certificate *tls.Certificate
+}
+
+// NewNative returns a native mock server generating responses as set by the generation and security settings of the config
+func NewNative(config mocking.Config, specs []mocking.Spec, port uint32, options NativeOptions) (*NativeMockServer, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("no API spec to mock")
+	}
+
+	switch options.CORS {
+	case "":
+		options.CORS = CORSSpec
+	case CORSSpec, CORSPermissive, CORSOff:
+	default:
+		return nil, fmt.Errorf("invalid CORS policy %q, expected %s, %s or %s", options.CORS, CORSSpec, CORSPermissive, CORSOff)
+	}
+
+	switch {
+	case options.JournalSize == 0:
+		options.JournalSize = DefaultJournalSize
+	case options.JournalSize < 0:
+		return nil, fmt.Errorf("invalid journal size %d, expected a positive number of requests", options.JournalSize)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mocking config: %w", err)
+	}
+
+	m := &NativeMockServer{
+		specs:      specs,
+		port:       port,
+		generation: config.Generation,
+		options:    options,
+		stores:     map[string]*resourceStore{},
+		handler:    &swappableHandler{},
+		logCh:      make(chan mocking.AccessLogEntry, accessLogBuffer),
+		errCh:      make(chan error),
+	}
+	if config.Security != nil {
+		m.tokens = config.Security.Tokens
+	}
+	if options.Seed != nil {
+		m.generation.Seed = options.Seed
+	}
+	if options.Scenario != nil {
+		m.scenario = newScenarioPlayer(options.Scenario)
+	}
+	if options.Admin {
+		m.journal = newRequestJournal(options.JournalSize)
+		m.examples = newActiveExamples()
+	}
+
+	return m, nil
+}
+
+// Start begins serving the APIs on the address, 127.0.0.1 unless set, and returns once the server is listening
+func (m *NativeMockServer) Start(ctx context.Context) error {
+	handler, err := m.newHandler(m.specs)
+	if err != nil {
+		return err
+	}
+	m.handler.set(handler)
+
+	address := m.options.Address
+	if address == "" {
+		address = "127.0.0.1"
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(address, fmt.Sprint(m.port)))
+	if err != nil {
+		return fmt.Errorf("unable to start mocking server: %w", err)
+	}
+
+	m.server = &http.Server{
+		Handler: m.handler,
+	}
+
+	go func(server *http.Server) {
+		if err := serve(server, ln, m.options.Certificate); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.errCh <- fmt.Errorf("%w: %s", mocking.ErrBackendExited, err)
+		}
+	}(m.server)
+
+	return nil
+}
+
+// newHandler validates the specs along with the options and returns the handler serving them
+func (m *NativeMockServer) newHandler(specs []mocking.Spec) (http.Handler, error) {
+	apiSpecs := make([]*openapi3.T, 0, len(specs))
+	for _, s := range specs {
+		apiSpecs = append(apiSpecs, s.API)
+	}
+
+	if m.scenario != nil {
+		if err := validateScenario(m.options.Scenario, apiSpecs...); err != nil {
+			return nil, err
+		}
+	}
+
+	var rec *recorder
+	if m.options.Upstream != nil {
+		rec = newRecorder(m.options.Upstream, m.options.RecordDir)
+	}
+
+	var fixtures fixtureSet
+	if m.options.Fixtures != nil {
+		fixtures = newFixtureSet(m.options.Fixtures)
+	}
+
+	handlers := make(specMux, 0, len(specs))
+	for _, s := range specs {
+		opts, err := spec.GetOptions(s.API)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse x-kusk options of %s: %w", s.Name, err)
+		}
+
+		if s.Prefix != "" {
+			overridePathPrefix(opts, s.Prefix)
+		}
+
+		handler := &mockHandler{
+			router:           newRouter(s.API, opts),
+			cors:             m.options.CORS,
+			enforceSecurity:  m.options.EnforceSecurity,
+			tokens:           m.tokens,
+			generation:       m.generation,
+			validateRequests: m.options.ValidateRequests,
+			logCh:            m.logCh,
+			scenario:         m.scenario,
+			recorder:         rec,
+			fixtures:         fixtures,
+			journal:          m.journal,
+			examples:         m.examples,
+		}
+		// requests are only tagged with the spec that served them when there's more than one
+		if len(specs) > 1 {
+			handler.name = s.Name
+		}
+		if m.options.Stateful {
+			if _, ok := m.stores[s.Name]; !ok {
+				m.stores[s.Name] = newResourceStore()
+			}
+			handler.store = m.stores[s.Name]
+			handler.resources = inferResources(s.API)
+		}
+
+		handlers = append(handlers, handler)
+	}
+
+	if m.options.Admin {
+		return adminMux{
+			admin: &adminHandler{server: m, operations: newOperationIndex(specs)},
+			mock:  handlers,
+		}, nil
+	}
+
+	return handlers, nil
+}
+
+// reset forgets the requests served, stored resources and active examples and starts scenarios over
+func (m *NativeMockServer) reset() {
+	m.journal.reset()
+	m.examples.reset()
+	for _, store := range m.stores {
+		store.reset()
+	}
+	if m.scenario != nil {
+		m.scenario.reset()
+	}
+}
+
+// overridePathPrefix serves all operations under the prefix instead of the prefix set in x-kusk
+func overridePathPrefix(opts *options.Options, prefix string) {
+	for key, subOptions := range opts.OperationFinalSubOptions {
+		pathOptions := options.PathOptions{}
+		if subOptions.Path != nil {
+			pathOptions = *subOptions.Path
+		}
+		pathOptions.Prefix = prefix
+
+		subOptions.Path = &pathOptions
+		opts.OperationFinalSubOptions[key] = subOptions
+	}
+}
+
+// Reload validates the given specs and swaps them in without closing the listener, so requests keep being served.
+// The previous specs keep being served when the given specs are invalid.
+func (m *NativeMockServer) Reload(ctx context.Context, specs []mocking.Spec) error {
+	handler, err := m.newHandler(specs)
+	if err != nil {
+		return err
+	}
+
+	m.specs = specs
+	m.handler.set(handler)
+
+	return nil
+}
+
+func (m *NativeMockServer) Stop(ctx context.Context) error {
+	if m.server == nil {
+		return nil
+	}
+
+	return m.server.Shutdown(ctx)
+}
+
+func (m *NativeMockServer) Logs() <-chan mocking.AccessLogEntry {
+	return m.logCh
+}
+
+func (m *NativeMockServer) Errors() <-chan error {
+	return m.errCh
+}
+
+// swappableHandler serves requests with the handler set last, requests already being served finish with the previous one
+type swappableHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+func (h *swappableHandler) set(handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handler = handler
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+// serve serves HTTP on the listener, or HTTPS with HTTP/2 when a certificate is given
+func serve(server *http.Server, ln net.Listener, certificate *tls.Certificate) error {
+	if certificate == nil {
+		return server.Serve(ln)
+	}
+
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{*certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// the certificate is already in the TLS config, ServeTLS sets up HTTP/2 on top of it
+	return server.ServeTLS(ln, "", "")
+}
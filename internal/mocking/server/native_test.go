@@ -0,0 +1,1024 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+func newTestHandler(t *testing.T, useExamples string) (*mockHandler, chan mocking.AccessLogEntry) {
+	t.Helper()
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile("testdata/todos.yaml")
+	require.NoError(t, err)
+
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+
+	generation := mocking.DefaultConfig().Generation
+	generation.UseExamples = useExamples
+
+	logCh := make(chan mocking.AccessLogEntry, 100)
+	return &mockHandler{
+		router:     newRouter(apiSpec, opts),
+		generation: generation,
+		logCh:      logCh,
+	}, logCh
+}
+
+func TestMockHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		accept         string
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+	}{
+		{
+			name:           "generates from schema",
+			method:         http.MethodGet,
+			path:           "/todos/1",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "serves example for accepted media type",
+			method:         http.MethodGet,
+			path:           "/todos",
+			accept:         "application/xml",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/xml",
+			expectedBody:   "<doc><completed>true</completed><order>13</order><title>Mocked XML title</title></doc>",
+		},
+		{
+			name:           "lowest 2xx response",
+			method:         http.MethodPost,
+			path:           "/todos",
+			expectedStatus: http.StatusCreated,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "response without content",
+			method:         http.MethodDelete,
+			path:           "/todos/1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown path",
+			method:         http.MethodGet,
+			path:           "/unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "unknown method",
+			method:         http.MethodPatch,
+			path:           "/todos/1",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedType:   "application/json",
+		},
+	}
+
+	handler, _ := newTestHandler(t, mocking.UseExamplesIfPresent)
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			req := httptest.NewRequest(testCase.method, testCase.path, nil)
+			if testCase.accept != "" {
+				req.Header.Set("Accept", testCase.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(testCase.expectedStatus, rec.Code)
+			assert.Equal(testCase.expectedType, rec.Header().Get("Content-Type"))
+			if testCase.expectedBody != "" {
+				assert.Equal(testCase.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMockHandlerGeneratesValidTodo(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	handler, logCh := newTestHandler(t, mocking.UseExamplesNo)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+
+	var todo map[string]interface{}
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &todo))
+	for _, property := range []string{"title", "completed", "order", "url"} {
+		assert.Contains(todo, property)
+	}
+
+	entry := <-logCh
+	assert.Equal("/todos/1", entry.Path)
+	assert.Equal("200", entry.StatusCode)
+	assert.Equal("getTodo", entry.Operation)
+	assert.Equal(mocking.SourceSchema, entry.Source)
+}
+
+func TestMockHandlerDoesNotWaitForUnreadLogs(t *testing.T) {
+	t.Parallel()
+
+	handler, _ := newTestHandler(t, mocking.UseExamplesNo)
+	handler.logCh = make(chan mocking.AccessLogEntry)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMockHandlerValidatesRequests(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		method            string
+		path              string
+		body              string
+		expectedStatus    int
+		expectedLocations []string
+	}{
+		{
+			name:           "valid request",
+			method:         http.MethodGet,
+			path:           "/todos?limit=10",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:              "invalid query parameter",
+			method:            http.MethodGet,
+			path:              "/todos?limit=1000",
+			expectedStatus:    http.StatusBadRequest,
+			expectedLocations: []string{"query.limit"},
+		},
+		{
+			name:              "invalid path parameter",
+			method:            http.MethodGet,
+			path:              "/todos/abc",
+			expectedStatus:    http.StatusBadRequest,
+			expectedLocations: []string{"path.id"},
+		},
+		{
+			name:              "invalid request body",
+			method:            http.MethodPost,
+			path:              "/todos",
+			body:              `{"title": 1, "completed": true, "order": 1}`,
+			expectedStatus:    http.StatusBadRequest,
+			expectedLocations: []string{"body.title", "body.url"},
+		},
+		{
+			name:              "invalid parameter and request body",
+			method:            http.MethodPut,
+			path:              "/todos/abc",
+			body:              `{"title": "title", "completed": "yes", "order": 1, "url": "http://example.com"}`,
+			expectedStatus:    http.StatusBadRequest,
+			expectedLocations: []string{"path.id", "body.completed"},
+		},
+	}
+
+	handler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+	handler.validateRequests = true
+
+	for _, testCase := range testCases {
+		assert := assert.New(t)
+
+		req := httptest.NewRequest(testCase.method, testCase.path, strings.NewReader(testCase.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		entry := <-logCh
+
+		assert.Equal(testCase.expectedStatus, rec.Code, testCase.name)
+		if testCase.expectedStatus != http.StatusBadRequest {
+			assert.NoError(entry.Error, testCase.name)
+			continue
+		}
+
+		var resp errorResponse
+		assert.NoError(json.Unmarshal(rec.Body.Bytes(), &resp), testCase.name)
+
+		var locations []string
+		for _, e := range resp.Errors {
+			locations = append(locations, e.Location)
+		}
+		assert.ElementsMatch(testCase.expectedLocations, locations, testCase.name)
+		assert.Error(entry.Error, testCase.name)
+	}
+}
+
+func TestMockHandlerStateful(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	handler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+	handler.store = newResourceStore()
+	handler.resources = inferResources(handler.router.spec)
+
+	do := func(method, path, body string) (int, interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		<-logCh
+
+		var resp interface{}
+		if rec.Body.Len() > 0 {
+			assert.NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	status, _ := do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusNotFound, status)
+
+	status, created := do(http.MethodPost, "/todos", `{"title": "first"}`)
+	assert.Equal(http.StatusCreated, status)
+	assert.Equal("first", created.(map[string]interface{})["title"])
+	assert.Equal(float64(1), created.(map[string]interface{})["id"])
+	assert.Contains(created, "url")
+
+	status, fetched := do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusOK, status)
+	assert.Equal(created, fetched)
+
+	status, updated := do(http.MethodPut, "/todos/1", `{"title": "updated", "completed": true, "order": 1, "url": "http://example.com"}`)
+	assert.Equal(http.StatusOK, status)
+	assert.Equal("updated", updated.(map[string]interface{})["title"])
+	assert.Equal(float64(1), updated.(map[string]interface{})["id"])
+
+	do(http.MethodPost, "/todos", `{"title": "second"}`)
+	status, list := do(http.MethodGet, "/todos", "")
+	assert.Equal(http.StatusOK, status)
+	assert.Len(list, 2)
+
+	status, _ = do(http.MethodDelete, "/todos/1", "")
+	assert.Equal(http.StatusNoContent, status)
+
+	status, _ = do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusNotFound, status)
+
+	_, list = do(http.MethodGet, "/todos", "")
+	assert.Len(list, 1)
+}
+
+func TestMockHandlerStatefulNestedCollections(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	apiSpec := loadTestSpec(t, "testdata/comments.yaml")
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+
+	logCh := make(chan mocking.AccessLogEntry, 100)
+	handler := &mockHandler{
+		router:     newRouter(apiSpec, opts),
+		generation: mocking.DefaultConfig().Generation,
+		logCh:      logCh,
+		store:      newResourceStore(),
+		resources:  inferResources(apiSpec),
+	}
+
+	do := func(method, path, body string) (int, interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		<-logCh
+
+		var resp interface{}
+		if rec.Body.Len() > 0 {
+			assert.NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	status, created := do(http.MethodPost, "/todos/1/comments", `{"text": "on the first todo"}`)
+	assert.Equal(http.StatusCreated, status)
+	assert.Equal(float64(1), created.(map[string]interface{})["id"])
+
+	_, list := do(http.MethodGet, "/todos/1/comments", "")
+	assert.Len(list, 1)
+
+	// comments of another todo are another collection
+	_, list = do(http.MethodGet, "/todos/2/comments", "")
+	assert.Len(list, 0)
+
+	status, _ = do(http.MethodGet, "/todos/2/comments/1", "")
+	assert.Equal(http.StatusNotFound, status)
+
+	status, fetched := do(http.MethodGet, "/todos/1/comments/1", "")
+	assert.Equal(http.StatusOK, status)
+	assert.Equal(created, fetched)
+}
+
+func TestMockHandlerScenario(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	scenario := &mocking.Scenario{
+		Operations: map[string]mocking.OperationScenario{
+			"getTodo": {
+				Steps: []mocking.ScenarioStep{
+					{Status: http.StatusServiceUnavailable, Delay: "1ms-5ms"},
+					{Example: "done"},
+				},
+				Loop: true,
+			},
+			"DELETE /todos/{id}": {
+				Steps: []mocking.ScenarioStep{{Status: http.StatusNotFound}},
+			},
+		},
+	}
+
+	handler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+	assert.NoError(validateScenario(scenario, handler.router.spec))
+	handler.scenario = newScenarioPlayer(scenario)
+
+	do := func(method, path string) (*httptest.ResponseRecorder, mocking.AccessLogEntry) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec, <-logCh
+	}
+
+	rec, entry := do(http.MethodGet, "/todos/1")
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+	assert.Equal("getTodo step 1/2: status 503, delay 1ms-5ms", entry.Scenario)
+
+	rec, entry = do(http.MethodGet, "/todos/1")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.JSONEq(`{"id": 1, "title": "Done todo", "completed": true, "order": 1, "url": "http://localhost/todos/1"}`, rec.Body.String())
+	assert.Equal("getTodo step 2/2: example done", entry.Scenario)
+
+	rec, _ = do(http.MethodGet, "/todos/1")
+	assert.Equal(http.StatusServiceUnavailable, rec.Code, "scenario should loop")
+
+	for i := 0; i < 2; i++ {
+		rec, entry = do(http.MethodDelete, "/todos/1")
+		assert.Equal(http.StatusNotFound, rec.Code, "last step should repeat")
+		assert.Equal("DELETE /todos/{id} step 1/1: status 404", entry.Scenario)
+	}
+
+	rec, entry = do(http.MethodGet, "/todos")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Empty(entry.Scenario)
+
+	handler.scenario.reset()
+	rec, _ = do(http.MethodGet, "/todos/1")
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestValidateScenario(t *testing.T) {
+	t.Parallel()
+
+	handler, _ := newTestHandler(t, mocking.UseExamplesIfPresent)
+
+	err := validateScenario(&mocking.Scenario{
+		Operations: map[string]mocking.OperationScenario{"listUsers": {Steps: []mocking.ScenarioStep{{}}}},
+	}, handler.router.spec)
+	assert.ErrorContains(t, err, "listUsers not found")
+
+	err = validateScenario(&mocking.Scenario{
+		Operations: map[string]mocking.OperationScenario{"getTodo": {Steps: []mocking.ScenarioStep{{Example: "missing"}}}},
+	}, handler.router.spec)
+	assert.ErrorContains(t, err, "example missing not found")
+}
+
+func TestMockHandlerRecordAndReplay(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "real")
+		fmt.Fprintf(w, `{"id": 7, "title": "real todo for %s", "completed": false, "order": 1, "url": "http://upstream"}`, r.URL.RawQuery)
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	recordingHandler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+	recordingHandler.recorder = newRecorder(upstreamURL, dir)
+
+	rec := httptest.NewRecorder()
+	recordingHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/7?expand=true", nil))
+	entry := <-logCh
+	assert.NoError(entry.Error)
+	assert.Equal(mocking.SourceUpstream, entry.Source)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("real", rec.Header().Get("X-Upstream"))
+
+	fixtures, err := mocking.LoadFixtures(dir)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 1)
+	assert.Equal("getTodo", fixtures[0].Operation)
+	assert.Equal(mocking.FixtureRequest{Method: http.MethodGet, Path: "/todos/7", Query: "expand=true"}, fixtures[0].Request)
+
+	replayingHandler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+	replayingHandler.fixtures = newFixtureSet(fixtures)
+
+	rec = httptest.NewRecorder()
+	replayingHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/7", nil))
+	entry = <-logCh
+	assert.Equal(mocking.SourceFixture, entry.Source)
+	assert.Equal("real", rec.Header().Get("X-Upstream"))
+	assert.JSONEq(`{"id": 7, "title": "real todo for expand=true", "completed": false, "order": 1, "url": "http://upstream"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	replayingHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/8", nil))
+	entry = <-logCh
+	assert.Equal(mocking.SourceExample, entry.Source, "requests without fixtures should be mocked from the spec")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Empty(rec.Header().Get("X-Upstream"))
+}
+
+func TestMockHandlerRecordAndReplayBodies(t *testing.T) {
+	t.Parallel()
+
+	png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0xff, 0xfe}
+	testCases := []struct {
+		name         string
+		contentType  string
+		body         []byte
+		expectedBody string
+		encoding     string
+	}{
+		{
+			name:         "json with charset",
+			contentType:  "application/json; charset=utf-8",
+			body:         []byte(`{"id":7}`),
+			expectedBody: `{"id": 7}`,
+		},
+		{
+			name:         "text",
+			contentType:  "text/plain",
+			body:         []byte("real todo\n"),
+			expectedBody: `"real todo\n"`,
+		},
+		{
+			name:         "binary",
+			contentType:  "image/png",
+			body:         png,
+			expectedBody: `"` + base64.StdEncoding.EncodeToString(png) + `"`,
+			encoding:     mocking.BodyEncodingBase64,
+		},
+	}
+
+	for _, tc := range testCases {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			w.Write(tc.body)
+		}))
+		defer upstream.Close()
+
+		upstreamURL, err := url.Parse(upstream.URL)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+
+		recordingHandler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+		recordingHandler.recorder = newRecorder(upstreamURL, dir)
+		recordingHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todos/7", nil))
+		require.NoError(t, (<-logCh).Error, tc.name)
+
+		fixtures, err := mocking.LoadFixtures(dir)
+		require.NoError(t, err)
+		require.Len(t, fixtures, 1, tc.name)
+		assert.JSONEq(t, tc.expectedBody, string(fixtures[0].Response.Body), tc.name)
+		assert.Equal(t, tc.encoding, fixtures[0].Response.BodyEncoding, tc.name)
+
+		replayingHandler, logCh := newTestHandler(t, mocking.UseExamplesIfPresent)
+		replayingHandler.fixtures = newFixtureSet(fixtures)
+
+		rec := httptest.NewRecorder()
+		replayingHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos/7", nil))
+		assert.Equal(t, mocking.SourceFixture, (<-logCh).Source, tc.name)
+		assert.Equal(t, tc.body, rec.Body.Bytes(), tc.name)
+	}
+}
+
+func TestMockHandlerSeed(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	get := func(seed int64, path string) string {
+		handler, logCh := newTestHandler(t, mocking.UseExamplesNo)
+		handler.generation.Seed = &seed
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		<-logCh
+
+		return rec.Body.String()
+	}
+
+	body := get(42, "/todos/1?a=1&b=2")
+	assert.Equal(body, get(42, "/todos/1?a=1&b=2"))
+	assert.Equal(body, get(42, "/todos/1?b=2&a=1"), "query parameter order should not matter")
+	assert.NotEqual(body, get(42, "/todos/2?a=1&b=2"))
+	assert.NotEqual(body, get(42, "/todos/1"))
+	assert.NotEqual(body, get(7, "/todos/1?a=1&b=2"))
+}
+
+// startTestServer starts a native mock server for the specs on a free port and returns
+// the server and its URL along with the channel its access log entries are buffered on
+func startTestServer(t *testing.T, options NativeOptions, specs ...mocking.Spec) (*NativeMockServer, string, <-chan mocking.AccessLogEntry) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+
+	server, err := NewNative(mocking.DefaultConfig(), specs, uint32(port), options)
+	require.NoError(t, err)
+	require.NoError(t, server.Start(context.Background()))
+	t.Cleanup(func() {
+		server.Stop(context.Background())
+	})
+
+	// the access log is sent once the response is written, so it has to be read while requests are in flight
+	logCh := make(chan mocking.AccessLogEntry, 100)
+	go func() {
+		for entry := range server.Logs() {
+			logCh <- entry
+		}
+	}()
+
+	return server, fmt.Sprintf("http://127.0.0.1:%d", port), logCh
+}
+
+func loadTestSpec(t *testing.T, path string) *openapi3.T {
+	t.Helper()
+
+	apiSpec, err := openapi3.NewLoader().LoadFromFile(path)
+	require.NoError(t, err)
+
+	return apiSpec
+}
+
+func TestNativeMockServerMultipleSpecs(t *testing.T) {
+	assert := assert.New(t)
+
+	_, baseURL, logCh := startTestServer(t, NativeOptions{},
+		mocking.Spec{Name: "todos", Prefix: "/todo-service", API: loadTestSpec(t, "testdata/todos.yaml")},
+		mocking.Spec{Name: "users", API: loadTestSpec(t, "testdata/users.yaml")},
+	)
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+		expectedSpec   string
+	}{
+		{path: "/todo-service/todos/1", expectedStatus: http.StatusOK, expectedSpec: "todos"},
+		{path: "/accounts/users/1", expectedStatus: http.StatusOK, expectedSpec: "users"},
+		{path: "/todos/1", expectedStatus: http.StatusNotFound},
+		{path: "/users/1", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		resp, err := http.Get(baseURL + tc.path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		entry := <-logCh
+		assert.Equal(tc.expectedStatus, resp.StatusCode, tc.path)
+		assert.Equal(tc.expectedSpec, entry.Spec, tc.path)
+	}
+}
+
+func TestNativeMockServerAdmin(t *testing.T) {
+	assert := assert.New(t)
+
+	_, baseURL, logCh := startTestServer(t, NativeOptions{Admin: true, Stateful: true},
+		mocking.Spec{Name: "todos", API: loadTestSpec(t, "testdata/todos.yaml")},
+	)
+
+	do := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		if !strings.HasPrefix(path, AdminPathPrefix) {
+			<-logCh
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	type requests struct {
+		Count    int               `json:"count"`
+		Requests []recordedRequest `json:"requests"`
+	}
+	listRequests := func(query string) requests {
+		status, body := do(http.MethodGet, AdminPathPrefix+"/requests"+query, "")
+		require.Equal(t, http.StatusOK, status)
+
+		var list requests
+		require.NoError(t, json.Unmarshal([]byte(body), &list))
+		return list
+	}
+
+	do(http.MethodPost, "/todos", `{"title": "first"}`)
+	do(http.MethodPost, "/todos", `{"title": "second"}`)
+	do(http.MethodGet, "/todos/1", "")
+
+	list := listRequests("?operation=POST%20/todos")
+	assert.Equal(2, list.Count)
+	assert.JSONEq(`{"title": "first"}`, string(list.Requests[0].Body))
+	assert.Equal(http.StatusCreated, list.Requests[0].Status)
+	assert.Equal(1, listRequests("?operation=getTodo").Count)
+	assert.Equal(1, listRequests("?operation=GET%20/todos/%7Bid%7D").Count, "operations with an operationId should be found by method and path")
+	assert.Equal(3, listRequests("").Count)
+
+	status, _ := do(http.MethodGet, AdminPathPrefix+"/requests?operation=unknown", "")
+	assert.Equal(http.StatusNotFound, status)
+
+	status, _ = do(http.MethodPut, AdminPathPrefix+"/examples/getTodo", `{"example": "missing"}`)
+	assert.Equal(http.StatusBadRequest, status)
+
+	status, _ = do(http.MethodPut, AdminPathPrefix+"/examples/getTodo", `{"example": "done"}`)
+	assert.Equal(http.StatusOK, status)
+
+	status, body := do(http.MethodGet, "/todos/5", "")
+	assert.Equal(http.StatusOK, status)
+	assert.JSONEq(`{"id": 1, "title": "Done todo", "completed": true, "order": 1, "url": "http://localhost/todos/1"}`, body)
+
+	status, _ = do(http.MethodDelete, AdminPathPrefix+"/examples/getTodo", "")
+	assert.Equal(http.StatusNoContent, status)
+
+	status, body = do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusOK, status)
+	assert.Contains(body, `"title":"first"`)
+
+	// the example of an operation with an operationId can be switched by method and path too
+	status, _ = do(http.MethodPut, AdminPathPrefix+"/examples/GET%20/todos/%7Bid%7D", `{"example": "done"}`)
+	assert.Equal(http.StatusOK, status)
+
+	status, body = do(http.MethodGet, "/todos/5", "")
+	assert.Equal(http.StatusOK, status)
+	assert.JSONEq(`{"id": 1, "title": "Done todo", "completed": true, "order": 1, "url": "http://localhost/todos/1"}`, body)
+
+	status, _ = do(http.MethodDelete, AdminPathPrefix+"/examples/GET%20/todos/%7Bid%7D", "")
+	assert.Equal(http.StatusNoContent, status)
+
+	status, body = do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusOK, status)
+	assert.Contains(body, `"title":"first"`)
+
+	status, _ = do(http.MethodPost, AdminPathPrefix+"/reset", "")
+	assert.Equal(http.StatusNoContent, status)
+	assert.Equal(0, listRequests("").Count)
+
+	status, _ = do(http.MethodGet, "/todos/1", "")
+	assert.Equal(http.StatusNotFound, status, "stored resources should be reset")
+
+	status, _ = do(http.MethodGet, AdminPathPrefix+"/unknown", "")
+	assert.Equal(http.StatusNotFound, status)
+}
+
+func TestRequestJournal(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	journal := newRequestJournal(3)
+	paths := func() []string {
+		paths := []string{}
+		for _, request := range journal.list(nil, "", "") {
+			paths = append(paths, request.Path)
+		}
+		return paths
+	}
+
+	for i := 1; i <= 5; i++ {
+		journal.add(recordedRequest{Method: http.MethodGet, Path: fmt.Sprintf("/todos/%d", i)})
+	}
+	assert.Equal([]string{"/todos/3", "/todos/4", "/todos/5"}, paths(), "only the latest requests should be kept, oldest first")
+
+	journal.reset()
+	assert.Empty(paths())
+
+	journal.add(recordedRequest{Method: http.MethodGet, Path: "/todos/6"})
+	assert.Equal([]string{"/todos/6"}, paths())
+
+	_, err := NewNative(mocking.DefaultConfig(), []mocking.Spec{{API: loadTestSpec(t, "testdata/todos.yaml")}}, 8080, NativeOptions{Admin: true, JournalSize: -1})
+	assert.Error(err)
+}
+
+func TestRedactHeaders(t *testing.T) {
+	t.Parallel()
+
+	headers := http.Header{
+		"Authorization": {"Bearer secret-token"},
+		"Cookie":        {"session=secret"},
+		"X-Api-Key":     {"secret-key"},
+		"Accept":        {"application/json"},
+	}
+
+	redacted := redactHeaders(headers, loadTestSpec(t, "testdata/secure.yaml"))
+	assert.Equal(t, http.Header{
+		"Authorization": {redactedHeader},
+		"Cookie":        {redactedHeader},
+		"X-Api-Key":     {redactedHeader},
+		"Accept":        {"application/json"},
+	}, redacted)
+	assert.Equal(t, "Bearer secret-token", headers.Get("Authorization"), "the headers of the request should be left as is")
+}
+
+func TestOperationIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	todos := loadTestSpec(t, "testdata/todos.yaml")
+	index := newOperationIndex([]mocking.Spec{{Name: "todos", API: todos}, {Name: "archive", API: todos}})
+
+	_, err := index.find("GET /todos/{id}")
+	assert.EqualError(err, "operation GET /todos/{id} is served by several specs, use one of archive:getTodo, todos:getTodo")
+	assert.Len(index.lookup("getTodo"), 2)
+
+	operation, err := index.find("todos:GET /todos/{id}")
+	assert.NoError(err)
+	assert.Equal("todos:getTodo", operation.scopedKey())
+
+	_, err = index.find("users:getTodo")
+	assert.Error(err)
+
+	operation, err = newOperationIndex([]mocking.Spec{{Name: "todos", API: todos}}).find("GET /todos/{id}")
+	assert.NoError(err)
+	assert.Equal("getTodo", operation.scopedKey(), "a single spec isn't scoped")
+}
+
+func TestNativeMockServerReload(t *testing.T) {
+	assert := assert.New(t)
+
+	scenario := &mocking.Scenario{
+		Operations: map[string]mocking.OperationScenario{
+			"getTodo": {Steps: []mocking.ScenarioStep{{Example: "done"}}, Loop: true},
+		},
+	}
+	todos := mocking.Spec{Name: "todos", API: loadTestSpec(t, "testdata/todos.yaml")}
+	users := mocking.Spec{Name: "users", API: loadTestSpec(t, "testdata/users.yaml")}
+
+	server, baseURL, logCh := startTestServer(t, NativeOptions{Scenario: scenario}, todos)
+
+	get := func(path string) int {
+		resp, err := http.Get(baseURL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		<-logCh
+
+		return resp.StatusCode
+	}
+
+	// the scenario doesn't apply to the users spec so the todos spec keeps being served
+	assert.Error(server.Reload(context.Background(), []mocking.Spec{users}))
+	assert.Equal(http.StatusOK, get("/todos/1"))
+	assert.Equal(http.StatusNotFound, get("/accounts/users/1"))
+
+	assert.NoError(server.Reload(context.Background(), []mocking.Spec{todos, users}))
+	assert.Equal(http.StatusOK, get("/todos/1"))
+	assert.Equal(http.StatusOK, get("/accounts/users/1"))
+}
+
+func TestNativeMockServerTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	certFile, keyFile, err := mocking.SelfSignedCertificate(dir)
+	require.NoError(t, err)
+	pemCert, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+
+	// the certificate is only generated once
+	_, _, err = mocking.SelfSignedCertificate(dir)
+	require.NoError(t, err)
+	b, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	assert.Equal(pemCert, b)
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	_, baseURL, logCh := startTestServer(t, NativeOptions{Certificate: &certificate},
+		mocking.Spec{Name: "todos", API: loadTestSpec(t, "testdata/todos.yaml")},
+	)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(pemCert))
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get(strings.Replace(baseURL, "http://127.0.0.1", "https://localhost", 1) + "/todos/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	<-logCh
+
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(2, resp.ProtoMajor)
+}
+
+func TestMockHandlerCORS(t *testing.T) {
+	t.Parallel()
+
+	newHandler := func(path, cors string) *mockHandler {
+		apiSpec := loadTestSpec(t, path)
+		opts, err := spec.GetOptions(apiSpec)
+		require.NoError(t, err)
+
+		return &mockHandler{
+			router:     newRouter(apiSpec, opts),
+			cors:       cors,
+			generation: mocking.DefaultConfig().Generation,
+			logCh:      make(chan mocking.AccessLogEntry, 100),
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		handler         *mockHandler
+		method          string
+		path            string
+		headers         map[string]string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:            "preflight without x-kusk cors is permissive",
+			handler:         newHandler("testdata/todos.yaml", CORSSpec),
+			method:          http.MethodOptions,
+			path:            "/todos/1",
+			headers:         map[string]string{"Origin": "http://example.com", "Access-Control-Request-Method": "DELETE"},
+			expectedStatus:  http.StatusNoContent,
+			expectedHeaders: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Headers": "*"},
+		},
+		{
+			name:           "preflight for an undefined method",
+			handler:        newHandler("testdata/todos.yaml", CORSSpec),
+			method:         http.MethodOptions,
+			path:           "/todos/1",
+			headers:        map[string]string{"Origin": "http://example.com", "Access-Control-Request-Method": "PATCH"},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "preflight with x-kusk cors",
+			handler:        newHandler("testdata/users.yaml", CORSSpec),
+			method:         http.MethodOptions,
+			path:           "/accounts/users/1",
+			headers:        map[string]string{"Origin": "http://localhost:3000", "Access-Control-Request-Method": "GET"},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "http://localhost:3000",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Headers":     "Authorization",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:           "preflight from an origin x-kusk cors doesn't allow",
+			handler:        newHandler("testdata/users.yaml", CORSSpec),
+			method:         http.MethodOptions,
+			path:           "/accounts/users/1",
+			headers:        map[string]string{"Origin": "http://example.com", "Access-Control-Request-Method": "GET"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:            "request with x-kusk cors",
+			handler:         newHandler("testdata/users.yaml", CORSSpec),
+			method:          http.MethodGet,
+			path:            "/accounts/users/1",
+			headers:         map[string]string{"Origin": "http://localhost:3000"},
+			expectedStatus:  http.StatusOK,
+			expectedHeaders: map[string]string{"Access-Control-Allow-Origin": "http://localhost:3000", "Vary": "Origin"},
+		},
+		{
+			name:            "permissive overrides x-kusk cors",
+			handler:         newHandler("testdata/users.yaml", CORSPermissive),
+			method:          http.MethodGet,
+			path:            "/accounts/users/1",
+			headers:         map[string]string{"Origin": "http://example.com"},
+			expectedStatus:  http.StatusOK,
+			expectedHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:           "preflight with CORS off",
+			handler:        newHandler("testdata/todos.yaml", CORSOff),
+			method:         http.MethodOptions,
+			path:           "/todos/1",
+			headers:        map[string]string{"Origin": "http://example.com", "Access-Control-Request-Method": "GET"},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		for name, value := range tc.expectedHeaders {
+			assert.Equal(t, value, rec.Header().Get(name), tc.name+": "+name)
+		}
+		if tc.expectedHeaders == nil {
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), tc.name)
+		}
+	}
+}
+
+func TestMockHandlerEnforceSecurity(t *testing.T) {
+	t.Parallel()
+
+	apiSpec := loadTestSpec(t, "testdata/secure.yaml")
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+
+	handler := &mockHandler{
+		router:          newRouter(apiSpec, opts),
+		generation:      mocking.DefaultConfig().Generation,
+		logCh:           make(chan mocking.AccessLogEntry, 100),
+		enforceSecurity: true,
+		tokens: map[string][]string{
+			"bearerAuth": {"secret-token"},
+			"basicAuth":  {"admin:password"},
+		},
+	}
+
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	testCases := []struct {
+		name              string
+		path              string
+		headers           map[string]string
+		expectedStatus    int
+		expectedChallenge string
+	}{
+		{name: "no security", path: "/health", expectedStatus: http.StatusNoContent},
+		{name: "missing credentials", path: "/items", expectedStatus: http.StatusUnauthorized, expectedChallenge: "Bearer"},
+		{name: "accepted bearer token", path: "/items", headers: map[string]string{"Authorization": "Bearer secret-token"}, expectedStatus: http.StatusOK},
+		{name: "rejected bearer token", path: "/items", headers: map[string]string{"Authorization": "Bearer wrong"}, expectedStatus: http.StatusForbidden},
+		{name: "any api key without tokens", path: "/items", headers: map[string]string{"X-API-Key": "anything"}, expectedStatus: http.StatusOK},
+		{name: "missing basic auth", path: "/admin", headers: map[string]string{"Authorization": "Bearer secret-token"}, expectedStatus: http.StatusUnauthorized, expectedChallenge: `Basic realm="kusk mock"`},
+		{name: "accepted basic auth", path: "/admin", headers: map[string]string{"Authorization": basic("admin:password")}, expectedStatus: http.StatusNoContent},
+		{name: "rejected basic auth", path: "/admin", headers: map[string]string{"Authorization": basic("admin:wrong")}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expectedStatus, rec.Code, tc.name)
+		assert.Equal(t, tc.expectedChallenge, rec.Header().Get("WWW-Authenticate"), tc.name)
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+	"github.com/kubeshop/kusk/internal/mocking/generator"
+)
+
+// selectResponse picks the response the mock server should serve for an operation.
+// The lowest defined 2xx status code is preferred, then the default response and finally the lowest status code defined.
+func selectResponse(operation *openapi3.Operation) (int, *openapi3.Response) {
+	type candidate struct {
+		status   int
+		response *openapi3.Response
+	}
+
+	var candidates []candidate
+	for key, ref := range operation.Responses {
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+
+		status, ok := parseStatusCode(key)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{status: status, response: ref.Value})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].status < candidates[j].status
+	})
+
+	for _, c := range candidates {
+		if c.status >= 200 && c.status < 300 {
+			return c.status, c.response
+		}
+	}
+
+	if def := operation.Responses.Default(); def != nil && def.Value != nil {
+		return http.StatusOK, def.Value
+	}
+
+	if len(candidates) > 0 {
+		return candidates[0].status, candidates[0].response
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// parseStatusCode converts a response key such as 200 or 2XX to a status code
+func parseStatusCode(key string) (int, bool) {
+	if len(key) == 3 && strings.HasSuffix(strings.ToUpper(key), "XX") {
+		key = key[:1] + "00"
+	}
+
+	status, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, false
+	}
+
+	return status, true
+}
+
+// negotiateContent picks the media type of the response matching the Accept header,
+// preferring JSON when the client accepts anything
+func negotiateContent(response *openapi3.Response, accept string) (string, *openapi3.MediaType) {
+	if response == nil || len(response.Content) == 0 {
+		return "", nil
+	}
+
+	contentTypes := make([]string, 0, len(response.Content))
+	for contentType := range response.Content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+
+	for _, accepted := range strings.Split(accept, ",") {
+		accepted, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || accepted == "*/*" {
+			continue
+		}
+
+		for _, contentType := range contentTypes {
+			if mediaTypeMatches(accepted, contentType) {
+				return contentType, response.Content[contentType]
+			}
+		}
+	}
+
+	for _, contentType := range contentTypes {
+		if isJSON(contentType) {
+			return contentType, response.Content[contentType]
+		}
+	}
+
+	return contentTypes[0], response.Content[contentTypes[0]]
+}
+
+func mediaTypeMatches(accepted, contentType string) bool {
+	if accepted == contentType {
+		return true
+	}
+
+	// handle wildcards such as application/*
+	if strings.HasSuffix(accepted, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(accepted, "*"))
+	}
+
+	return false
+}
+
+// responseBody returns the body to serve for a media type and whether it came from an example.
+// Examples defined for the media type are preferred over values generated from its schema.
+func responseBody(mediaType *openapi3.MediaType, gen *generator.Generator) (interface{}, bool) {
+	if example := spec.GetExampleResponse(mediaType); example != nil {
+		return example, true
+	}
+
+	if mediaType.Schema == nil || mediaType.Schema.Value == nil {
+		return nil, false
+	}
+
+	return gen.Generate(mediaType.Schema.Value), false
+}
+
+// responseHeaders generates values for the headers defined on a response
+func responseHeaders(response *openapi3.Response, gen *generator.Generator) http.Header {
+	headers := http.Header{}
+	if response == nil {
+		return headers
+	}
+
+	for name, ref := range response.Headers {
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+
+		var value interface{}
+		switch {
+		case ref.Value.Example != nil:
+			value = ref.Value.Example
+		case ref.Value.Schema != nil && ref.Value.Schema.Value != nil:
+			value = gen.Generate(ref.Value.Schema.Value)
+		}
+
+		if value != nil {
+			headers.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	return headers
+}
+
+// encodeBody serialises the body according to the content type being served
+func encodeBody(contentType string, body interface{}) ([]byte, error) {
+	switch {
+	case body == nil:
+		return nil, nil
+	case isJSON(contentType):
+		return json.Marshal(body)
+	case isXML(contentType):
+		var buf bytes.Buffer
+		if err := encodeXML(&buf, "doc", body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	switch b := body.(type) {
+	case string:
+		return []byte(b), nil
+	case []byte:
+		return b, nil
+	case map[string]interface{}, []interface{}:
+		return json.Marshal(b)
+	}
+
+	return []byte(fmt.Sprint(body)), nil
+}
+
+func encodeXML(buf *bytes.Buffer, name string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(buf, "<%s>", name)
+		for _, k := range keys {
+			if err := encodeXML(buf, k, v[k]); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(buf, "</%s>", name)
+	case []interface{}:
+		if name == "doc" {
+			buf.WriteString("<doc>")
+			defer buf.WriteString("</doc>")
+			name = "item"
+		}
+		for _, item := range v {
+			if err := encodeXML(buf, name, item); err != nil {
+				return err
+			}
+		}
+	case nil:
+		fmt.Fprintf(buf, "<%s/>", name)
+	default:
+		fmt.Fprintf(buf, "<%s>", name)
+		if err := xml.EscapeText(buf, []byte(fmt.Sprint(v))); err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "</%s>", name)
+	}
+
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+func isXML(contentType string) bool {
+	return contentType == "application/xml" || contentType == "text/xml" || strings.HasSuffix(contentType, "+xml")
+}
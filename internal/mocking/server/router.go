@@ -0,0 +1,169 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk-gateway/pkg/options"
+)
+
+// router matches incoming requests against the path templates of an OpenAPI spec.
+// Unlike the kin-openapi routers it ignores the spec's servers, as the mock is always served from localhost,
+// and exposes the operations the way kusk gateway would according to their x-kusk options.
+type router struct {
+	spec   *openapi3.T
+	routes []pathRoute
+	// cors are the x-kusk cors options of the operations that set them, by method and path
+	cors map[string]*options.CORSOptions
+}
+
+type pathRoute struct {
+	// path is the path template in the spec
+	path string
+	// segments are the segments of the path template with the x-kusk path prefix applied
+	segments   []string
+	pathItem   *openapi3.PathItem
+	operations map[string]*openapi3.Operation
+}
+
+func newRouter(spec *openapi3.T, opts *options.Options) *router {
+	// the gateway only mocks the operations with mocking enabled. When no operation
+	// configures mocking all of them are mocked, as that's what kusk mock is run for
+	mockingConfigured := false
+	for _, subOptions := range opts.OperationFinalSubOptions {
+		if subOptions.Mocking != nil {
+			mockingConfigured = true
+			break
+		}
+	}
+
+	routesByPath := map[string]*pathRoute{}
+	cors := map[string]*options.CORSOptions{}
+	for path, pathItem := range spec.Paths {
+		for method, operation := range pathItem.Operations() {
+			subOptions := opts.OperationFinalSubOptions[method+path]
+			if subOptions.Disabled != nil && *subOptions.Disabled {
+				continue
+			}
+
+			if subOptions.CORS != nil {
+				cors[method+path] = subOptions.CORS
+			}
+
+			if mockingConfigured && !mockingEnabled(subOptions.Mocking) {
+				operation = nil
+			}
+
+			prefixedPath := path
+			if subOptions.Path != nil && subOptions.Path.Prefix != "" {
+				prefixedPath = strings.TrimSuffix(subOptions.Path.Prefix, "/") + path
+			}
+
+			route, ok := routesByPath[prefixedPath]
+			if !ok {
+				route = &pathRoute{
+					path:       path,
+					segments:   splitPath(prefixedPath),
+					pathItem:   pathItem,
+					operations: map[string]*openapi3.Operation{},
+				}
+				routesByPath[prefixedPath] = route
+			}
+			route.operations[method] = operation
+		}
+	}
+
+	r := &router{spec: spec, cors: cors}
+	for _, route := range routesByPath {
+		r.routes = append(r.routes, *route)
+	}
+
+	// paths with literal segments must win over templated ones, i.e. /pets/mine over /pets/{id}
+	sort.Slice(r.routes, func(i, j int) bool {
+		si, sj := r.routes[i].segments, r.routes[j].segments
+		for k := 0; k < len(si) && k < len(sj); k++ {
+			if pi, pj := isPathParam(si[k]), isPathParam(sj[k]); pi != pj {
+				return pj
+			}
+		}
+		if len(si) != len(sj) {
+			return len(si) > len(sj)
+		}
+		return strings.Join(si, "/") < strings.Join(sj, "/")
+	})
+
+	return r
+}
+
+func mockingEnabled(mocking *options.MockingOptions) bool {
+	return mocking != nil && mocking.Enabled != nil && *mocking.Enabled
+}
+
+// findRoute returns the operation the request resolves to along with its path parameters.
+// Operations that are exposed but not mocked resolve to a route without an operation.
+func (r *router) findRoute(req *http.Request) (*routers.Route, map[string]string, error) {
+	segments := splitPath(req.URL.Path)
+
+	var pathMatched bool
+	for _, route := range r.routes {
+		params, ok := matchSegments(route.segments, segments)
+		if !ok {
+			continue
+		}
+
+		pathMatched = true
+		operation, ok := route.operations[req.Method]
+		if !ok {
+			continue
+		}
+
+		return &routers.Route{
+			Spec:      r.spec,
+			Path:      route.path,
+			PathItem:  route.pathItem,
+			Method:    req.Method,
+			Operation: operation,
+		}, params, nil
+	}
+
+	if pathMatched {
+		return nil, nil, routers.ErrMethodNotAllowed
+	}
+
+	return nil, nil, routers.ErrPathNotFound
+}
+
+func matchSegments(template, actual []string) (map[string]string, bool) {
+	if len(template) != len(actual) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range template {
+		if isPathParam(segment) {
+			if actual[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = actual[i]
+			continue
+		}
+
+		if segment != actual[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func isPathParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubeshop/kusk-gateway/pkg/spec"
+)
+
+const xKuskSpec = `
+openapi: 3.0.0
+info:
+  title: pets
+  version: 0.0.1
+x-kusk:
+  path:
+    prefix: /api
+  mocking:
+    enabled: true
+paths:
+  /pets:
+    get:
+      responses:
+        '200':
+          description: ok
+    post:
+      x-kusk:
+        mocking:
+          enabled: false
+      responses:
+        '201':
+          description: created
+  /pets/mine:
+    get:
+      responses:
+        '200':
+          description: ok
+  /pets/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        '200':
+          description: ok
+  /internal:
+    x-kusk:
+      disabled: true
+    get:
+      responses:
+        '200':
+          description: ok
+  /v2/pets:
+    get:
+      x-kusk:
+        path:
+          prefix: /
+      responses:
+        '200':
+          description: ok
+`
+
+func TestRouterFindRoute(t *testing.T) {
+	t.Parallel()
+
+	apiSpec, err := openapi3.NewLoader().LoadFromData([]byte(xKuskSpec))
+	require.NoError(t, err)
+
+	opts, err := spec.GetOptions(apiSpec)
+	require.NoError(t, err)
+
+	r := newRouter(apiSpec, opts)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedPath   string
+		expectedParams map[string]string
+		notMocked      bool
+		expectedErr    error
+	}{
+		{
+			name:           "prefixed path",
+			method:         http.MethodGet,
+			path:           "/api/pets",
+			expectedPath:   "/pets",
+			expectedParams: map[string]string{},
+		},
+		{
+			name:        "path without prefix",
+			method:      http.MethodGet,
+			path:        "/pets",
+			expectedErr: routers.ErrPathNotFound,
+		},
+		{
+			name:           "literal segments win over parameters",
+			method:         http.MethodGet,
+			path:           "/api/pets/mine",
+			expectedPath:   "/pets/mine",
+			expectedParams: map[string]string{},
+		},
+		{
+			name:           "path parameters",
+			method:         http.MethodGet,
+			path:           "/api/pets/1",
+			expectedPath:   "/pets/{id}",
+			expectedParams: map[string]string{"id": "1"},
+		},
+		{
+			name:           "mocking disabled for operation",
+			method:         http.MethodPost,
+			path:           "/api/pets",
+			expectedPath:   "/pets",
+			expectedParams: map[string]string{},
+			notMocked:      true,
+		},
+		{
+			name:        "disabled path",
+			method:      http.MethodGet,
+			path:        "/api/internal",
+			expectedErr: routers.ErrPathNotFound,
+		},
+		{
+			name:           "prefix overridden by operation",
+			method:         http.MethodGet,
+			path:           "/v2/pets",
+			expectedPath:   "/v2/pets",
+			expectedParams: map[string]string{},
+		},
+		{
+			name:        "method not allowed",
+			method:      http.MethodDelete,
+			path:        "/api/pets",
+			expectedErr: routers.ErrMethodNotAllowed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			route, params, err := r.findRoute(httptest.NewRequest(testCase.method, testCase.path, nil))
+			if testCase.expectedErr != nil {
+				assert.ErrorIs(err, testCase.expectedErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(testCase.expectedPath, route.Path)
+			assert.Equal(testCase.expectedParams, params)
+			assert.Equal(testCase.notMocked, route.Operation == nil)
+		})
+	}
+}
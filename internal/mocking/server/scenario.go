@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/kubeshop/kusk/internal/mocking"
+)
+
+// scenarioPlayer keeps track of the scenario steps played back for each operation
+type scenarioPlayer struct {
+	scenario *mocking.Scenario
+
+	mu     sync.Mutex
+	played map[string]int
+}
+
+func newScenarioPlayer(scenario *mocking.Scenario) *scenarioPlayer {
+	return &scenarioPlayer{
+		scenario: scenario,
+		played:   map[string]int{},
+	}
+}
+
+// next returns the step to play for the operation of the route along with a description for the access log.
+// The step is nil when the operation isn't part of the scenario.
+func (p *scenarioPlayer) next(route *routers.Route) (*mocking.ScenarioStep, string) {
+	key := route.Operation.OperationID
+	operationScenario, ok := p.scenario.Operations[key]
+	if !ok || key == "" {
+		key = route.Method + " " + route.Path
+		if operationScenario, ok = p.scenario.Operations[key]; !ok {
+			return nil, ""
+		}
+	}
+
+	p.mu.Lock()
+	played := p.played[key]
+	p.played[key]++
+	p.mu.Unlock()
+
+	steps := operationScenario.Steps
+	i := played
+	if i >= len(steps) {
+		if operationScenario.Loop {
+			i = played % len(steps)
+		} else {
+			i = len(steps) - 1
+		}
+	}
+
+	step := steps[i]
+	return &step, fmt.Sprintf("%s step %d/%d: %s", key, i+1, len(steps), step)
+}
+
+// reset starts the scenario over for every operation
+func (p *scenarioPlayer) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.played = map[string]int{}
+}
+
+// validateScenario checks the scenario only refers to operations and examples that exist in the specs
+func validateScenario(scenario *mocking.Scenario, apiSpecs ...*openapi3.T) error {
+	// scenarios are played by operation regardless of the spec serving it
+	unnamed := make([]mocking.Spec, 0, len(apiSpecs))
+	for _, apiSpec := range apiSpecs {
+		unnamed = append(unnamed, mocking.Spec{API: apiSpec})
+	}
+	operations := newOperationIndex(unnamed)
+
+	keys := make([]string, 0, len(scenario.Operations))
+	for key := range scenario.Operations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		refs := operations.lookup(key)
+		if len(refs) == 0 {
+			return fmt.Errorf("scenario operation %s not found in the specs, use an operationId or a method and path such as \"GET /todos\"", key)
+		}
+
+		for _, ref := range refs {
+			for i, step := range scenario.Operations[key].Steps {
+				if step.Example == "" {
+					continue
+				}
+
+				status, response := selectResponse(ref.operation)
+				if step.Status != 0 {
+					status, response = step.Status, responseForStatus(ref.operation, step.Status)
+				}
+
+				if _, _, ok := namedExample(response, "", step.Example); !ok {
+					return fmt.Errorf("scenario operation %s step %d: example %s not found in the %d response", key, i+1, step.Example, status)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// responseForStatus returns the response of the operation matching the status code
+// through its exact code, its range such as 5XX or the default response
+func responseForStatus(operation *openapi3.Operation, status int) *openapi3.Response {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx"} {
+		if ref, ok := operation.Responses[key]; ok && ref.Value != nil {
+			return ref.Value
+		}
+	}
+
+	if def := operation.Responses.Default(); def != nil && def.Value != nil && status >= http.StatusBadRequest {
+		return def.Value
+	}
+
+	return nil
+}
+
+// namedExample finds the example with the given name in the response, preferring the given content type
+func namedExample(response *openapi3.Response, preferredContentType, name string) (string, interface{}, bool) {
+	if response == nil {
+		return "", nil, false
+	}
+
+	contentTypes := []string{preferredContentType}
+	for contentType := range response.Content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes[1:])
+
+	for _, contentType := range contentTypes {
+		mediaType, ok := response.Content[contentType]
+		if !ok || mediaType == nil {
+			continue
+		}
+
+		if example, ok := mediaType.Examples[name]; ok && example.Value != nil {
+			return contentType, example.Value.Value, true
+		}
+	}
+
+	return "", nil, false
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// credentialsError tells why a request doesn't meet the security requirements of its operation
+type credentialsError struct {
+	// status is 401 when credentials are missing and 403 when they aren't accepted
+	status int
+	// challenges are the WWW-Authenticate challenges of the schemes that could have been used
+	challenges []string
+	message    string
+}
+
+func (e *credentialsError) Error() string {
+	return e.message
+}
+
+// checkSecurity enforces the security requirements of the operation, which are met when all the schemes
+// of any of the requirements are satisfied. Schemes listing accepted tokens only accept those,
+// other schemes accept any value.
+func checkSecurity(r *http.Request, route *routers.Route, tokens map[string][]string) *credentialsError {
+	requirements := route.Spec.Security
+	if route.Operation.Security != nil {
+		requirements = *route.Operation.Security
+	}
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	schemes := route.Spec.Components.SecuritySchemes
+
+	var (
+		rejected   []string
+		missing    []string
+		challenges []string
+	)
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		met := true
+		for _, name := range names {
+			schemeRef, ok := schemes[name]
+			if !ok || schemeRef.Value == nil {
+				met = false
+				missing = append(missing, name+" (undefined security scheme)")
+				continue
+			}
+
+			credentials, challenge := credentialsFor(r, schemeRef.Value)
+			if challenge != "" {
+				challenges = append(challenges, challenge)
+			}
+			switch {
+			case credentials == "":
+				met = false
+				missing = append(missing, name)
+			case !accepted(tokens[name], credentials):
+				met = false
+				rejected = append(rejected, name)
+			}
+		}
+
+		// an empty requirement makes security optional
+		if met {
+			return nil
+		}
+	}
+
+	if len(rejected) > 0 {
+		return &credentialsError{
+			status:  http.StatusForbidden,
+			message: fmt.Sprintf("credentials not accepted for security scheme %s", strings.Join(unique(rejected), ", ")),
+		}
+	}
+
+	return &credentialsError{
+		status:     http.StatusUnauthorized,
+		challenges: unique(challenges),
+		message:    fmt.Sprintf("missing credentials for security scheme %s", strings.Join(unique(missing), " or ")),
+	}
+}
+
+// credentialsFor returns the credentials of the request for the scheme, empty when they're missing,
+// and the WWW-Authenticate challenge of the scheme when it uses the Authorization header
+func credentialsFor(r *http.Request, scheme *openapi3.SecurityScheme) (string, string) {
+	switch scheme.Type {
+	case "apiKey":
+		switch scheme.In {
+		case "header":
+			return r.Header.Get(scheme.Name), ""
+		case "query":
+			return r.URL.Query().Get(scheme.Name), ""
+		case "cookie":
+			if cookie, err := r.Cookie(scheme.Name); err == nil {
+				return cookie.Value, ""
+			}
+		}
+		return "", ""
+	case "http":
+		authScheme := strings.ToLower(scheme.Scheme)
+		challenge := scheme.Scheme
+		switch authScheme {
+		case "basic":
+			challenge = `Basic realm="kusk mock"`
+		case "bearer":
+			challenge = "Bearer"
+		}
+
+		credentials := authorization(r, authScheme)
+		if authScheme == "basic" && credentials != "" {
+			decoded, err := base64.StdEncoding.DecodeString(credentials)
+			if err != nil {
+				// invalid credentials rather than missing ones
+				return credentials, challenge
+			}
+			credentials = string(decoded)
+		}
+
+		return credentials, challenge
+	default:
+		// oauth2 and openIdConnect access tokens are sent as bearer tokens
+		return authorization(r, "bearer"), "Bearer"
+	}
+}
+
+// authorization returns the credentials of the Authorization header when it uses the auth scheme
+func authorization(r *http.Request, authScheme string) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], authScheme) {
+		return ""
+	}
+
+	return strings.TrimSpace(parts[1])
+}
+
+func accepted(tokens []string, credentials string) bool {
+	return len(tokens) == 0 || contains(tokens, credentials)
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
@@ -3,10 +3,16 @@ package server
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
@@ -15,55 +21,159 @@ import (
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+
+	"github.com/kubeshop/kusk/internal/mocking"
 )
 
+var _ mocking.Backend = (*MockServer)(nil)
+
+// MockServer is a mocking.Backend running the openapi-mock container through a Docker compatible daemon.
+// Requests are proxied to the container by kusk itself, so reloading can start a container with the new spec
+// and switch over to it once it's ready, without refusing connections in between.
 type MockServer struct {
 	client     *client.Client
 	image      string
 	configFile string
 	apiToMock  string
 	port       uint32
+	// certificate serves the proxy over HTTPS when set
+	certificate *tls.Certificate
 
-	LogCh chan AccessLogEntry
-	ErrCh chan error
-}
+	server *http.Server
+	// proxy forwards requests to the container currently serving
+	proxy     *swappableHandler
+	container *mockContainer
 
-type AccessLogEntry struct {
-	TimeStamp  string
-	Method     string
-	Path       string
-	StatusCode string
+	logCh chan mocking.AccessLogEntry
+	errCh chan error
+}
 
-	Error error
+// mockContainer is an openapi-mock container published on a random port of 127.0.0.1
+type mockContainer struct {
+	id   string
+	port string
+	// serving is closed once requests are proxied to the container
+	serving chan struct{}
+	// stopped is closed when the container is being stopped by us rather than exiting on its own
+	stopped chan struct{}
+	// exited is closed once the container has exited
+	exited chan struct{}
 }
 
-func New(ctx context.Context, client *client.Client, configFile, apiToMock string, port uint32) (MockServer, error) {
+// containerPort is the port openapi-mock listens on in the container
+const containerPort = nat.Port("8080/tcp")
+
+// containerStartTimeout is how long a container has to start answering requests
+const containerStartTimeout = 30 * time.Second
+
+func New(ctx context.Context, client *client.Client, configFile, apiToMock string, port uint32, certificate *tls.Certificate) (*MockServer, error) {
 	const openApiMockImage = "muonsoft/openapi-mock:v0.3.1"
 
 	reader, err := client.ImagePull(ctx, openApiMockImage, types.ImagePullOptions{})
 	if err != nil {
-		return MockServer{}, fmt.Errorf("unable to pull mock server image: %w", err)
+		return nil, fmt.Errorf("unable to pull mock server image: %w", err)
 	}
 
 	// wait for download to complete, discard output
 	defer reader.Close()
 	io.Copy(io.Discard, reader)
 
-	return MockServer{
-		client:     client,
-		image:      openApiMockImage,
-		configFile: configFile,
-		apiToMock:  apiToMock,
-		port:       port,
-		LogCh:      make(chan AccessLogEntry),
-		ErrCh:      make(chan error),
+	return &MockServer{
+		client:      client,
+		image:       openApiMockImage,
+		configFile:  configFile,
+		apiToMock:   apiToMock,
+		port:        port,
+		certificate: certificate,
+		proxy:       &swappableHandler{},
+		logCh:       make(chan mocking.AccessLogEntry),
+		errCh:       make(chan error),
 	}, nil
 }
 
-func (m MockServer) Start(ctx context.Context) (string, error) {
+func (m *MockServer) Start(ctx context.Context) error {
+	c, err := m.startContainer(ctx)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:"+fmt.Sprint(m.port))
+	if err != nil {
+		m.stopContainer(ctx, c)
+		return fmt.Errorf("unable to start mocking server: %w", err)
+	}
+
+	m.serve(c)
+	m.server = &http.Server{
+		Handler: m.proxy,
+	}
+
+	go func(server *http.Server) {
+		if err := serve(server, ln, m.certificate); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.errCh <- fmt.Errorf("%w: %s", mocking.ErrBackendExited, err)
+		}
+	}(m.server)
+
+	return nil
+}
+
+// Reload starts a new container so it picks up the latest version of the API spec and switches over to it
+// once it answers requests. The container reads the spec itself, so the parsed specs are not used.
+// The previous container keeps serving when the new one fails to start.
+func (m *MockServer) Reload(ctx context.Context, _ []mocking.Spec) error {
+	c, err := m.startContainer(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous := m.container
+	m.serve(c)
+
+	return m.stopContainer(ctx, previous)
+}
+
+func (m *MockServer) Stop(ctx context.Context) error {
+	if m.server != nil {
+		if err := m.server.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := m.stopContainer(ctx, m.container); err != nil {
+		return err
+	}
+
+	m.container = nil
+	return nil
+}
+
+func (m *MockServer) Logs() <-chan mocking.AccessLogEntry {
+	return m.logCh
+}
+
+func (m *MockServer) Errors() <-chan error {
+	return m.errCh
+}
+
+// serve proxies requests to the container from now on
+func (m *MockServer) serve(c *mockContainer) {
+	target := &url.URL{Scheme: "http", Host: "127.0.0.1:" + c.port}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, errorResponse{Status: http.StatusBadGateway, Message: err.Error()})
+	}
+
+	close(c.serving)
+	m.container = c
+	m.proxy.set(proxy)
+}
+
+// startContainer creates and starts a container serving the API spec and returns once it answers requests
+func (m *MockServer) startContainer(ctx context.Context) (*mockContainer, error) {
 	u, err := url.Parse(m.apiToMock)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	containerMockingConfigFilePath := "/app/mocking/openapi-mock.yaml"
@@ -83,7 +193,7 @@ func (m MockServer) Start(ctx context.Context) (string, error) {
 		ctx,
 		&container.Config{
 			Image:        m.image,
-			ExposedPorts: nat.PortSet{"8080": struct{}{}},
+			ExposedPorts: nat.PortSet{containerPort: struct{}{}},
 			Tty:          true,
 			AttachStdout: true,
 			AttachStderr: true,
@@ -100,9 +210,10 @@ func (m MockServer) Start(ctx context.Context) (string, error) {
 			AutoRemove: true,
 			Binds:      binds,
 			PortBindings: map[nat.Port][]nat.PortBinding{
-				nat.Port("8080"): {
+				// publish on a random port, the port of the mock server is served by the proxy
+				containerPort: {
 					{
-						HostIP: "127.0.0.1", HostPort: fmt.Sprint(m.port),
+						HostIP: "127.0.0.1",
 					},
 				},
 			},
@@ -113,69 +224,210 @@ func (m MockServer) Start(ctx context.Context) (string, error) {
 	)
 
 	if err != nil {
-		return "", fmt.Errorf("unable to create mocking server: %w", err)
+		return nil, fmt.Errorf("unable to create mocking server: %w", err)
 	}
 
+	// wait for the container before starting it so its exit can't be missed
+	statusCh, waitErrCh := m.client.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+
 	if err := m.client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return "", fmt.Errorf("unable to start mocking server: %w", err)
+		return nil, fmt.Errorf("unable to start mocking server: %w", err)
 	}
 
-	return resp.ID, nil
+	c := &mockContainer{
+		id:      resp.ID,
+		serving: make(chan struct{}),
+		stopped: make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+
+	go m.waitForExit(c, statusCh, waitErrCh)
+	go m.streamLogs(ctx, c)
+
+	info, err := m.client.ContainerInspect(ctx, c.id)
+	if err == nil {
+		if bindings := info.NetworkSettings.Ports[containerPort]; len(bindings) > 0 {
+			c.port = bindings[0].HostPort
+		} else {
+			err = errors.New("container port is not published")
+		}
+	}
+	if err != nil {
+		m.stopContainer(ctx, c)
+		return nil, fmt.Errorf("unable to inspect mocking server: %w", err)
+	}
+
+	if err := waitUntilReady(ctx, c); err != nil {
+		m.stopContainer(ctx, c)
+		return nil, err
+	}
+
+	return c, nil
 }
 
-func (m MockServer) Restart(ctx context.Context, MockServerId string) error {
-	timeout := 5 * time.Second
-	return m.client.ContainerRestart(ctx, MockServerId, &timeout)
+// waitUntilReady polls the container until it answers a request
+func waitUntilReady(ctx context.Context, c *mockContainer) error {
+	ctx, cancel := context.WithTimeout(ctx, containerStartTimeout)
+	defer cancel()
+
+	httpClient := &http.Client{Timeout: time.Second}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// any response will do, connections are reset until openapi-mock listens
+		resp, err := httpClient.Get("http://127.0.0.1:" + c.port + "/")
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		select {
+		case <-c.exited:
+			return errors.New("mocking server exited before serving the API, check the spec and the mocking config")
+		case <-ctx.Done():
+			return fmt.Errorf("mocking server did not start serving the API within %s", containerStartTimeout)
+		case <-ticker.C:
+		}
+	}
 }
 
-func (m MockServer) Stop(ctx context.Context, MockServerId string) error {
+func (m *MockServer) stopContainer(ctx context.Context, c *mockContainer) error {
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.stopped:
+		return nil
+	default:
+		close(c.stopped)
+	}
+
+	select {
+	case <-c.exited:
+		// exited on its own, already removed
+		return nil
+	default:
+	}
+
 	timeout := 5 * time.Second
-	return m.client.ContainerStop(ctx, MockServerId, &timeout)
+	if err := m.client.ContainerStop(ctx, c.id, &timeout); err != nil {
+		return err
+	}
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
 }
 
-func (m MockServer) ServerWait(ctx context.Context, MockServerId string) (<-chan container.ContainerWaitOKBody, <-chan error) {
-	return m.client.ContainerWait(ctx, MockServerId, container.WaitConditionNextExit)
+func (m *MockServer) waitForExit(c *mockContainer, statusCh <-chan container.ContainerWaitOKBody, errCh <-chan error) {
+	defer close(c.exited)
+
+	var err error
+	select {
+	case status := <-statusCh:
+		err = fmt.Errorf("%w with status code %d", mocking.ErrBackendExited, status.StatusCode)
+	case waitErr := <-errCh:
+		err = fmt.Errorf("%w: %s", mocking.ErrBackendExited, waitErr)
+	}
+
+	select {
+	case <-c.stopped:
+		// stopped on purpose, nothing to report
+		return
+	default:
+	}
+
+	select {
+	case <-c.serving:
+		m.errCh <- err
+	default:
+		// failing to start is reported by startContainer
+	}
 }
 
-func (m MockServer) StreamLogs(ctx context.Context, containerId string) {
-	reader, err := m.client.ContainerLogs(ctx, containerId, types.ContainerLogsOptions{
+func (m *MockServer) streamLogs(ctx context.Context, c *mockContainer) {
+	reader, err := m.client.ContainerLogs(ctx, c.id, types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
 		Timestamps: false,
 	})
 	if err != nil {
-		m.ErrCh <- err
+		m.errCh <- err
 		return
 	}
 	defer reader.Close()
 
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		if le, err := newAccessLogEntry(scanner.Text()); err != nil {
-			m.ErrCh <- err
-		} else {
-			m.LogCh <- le
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		le, err := newAccessLogEntry(scanner.Text())
+		if err != nil {
+			m.errCh <- err
+			continue
+		}
+
+		select {
+		case <-c.serving:
+			m.logCh <- le
+		default:
+			// requests checking whether the container is ready
 		}
 	}
 }
 
-func newAccessLogEntry(rawLog string) (AccessLogEntry, error) {
-	if strings.Contains(rawLog, "warning") || strings.Contains(rawLog, "error") {
-		return AccessLogEntry{}, errors.New(rawLog)
-	}
+// accessLogLine matches the access logs of the openapi-mock container, which are in the common log format, e.g.
+// 172.17.0.1 - - [17/Oct/2022:10:00:00 +0000] "GET /todos HTTP/1.1" 200 312
+var accessLogLine = regexp.MustCompile(`\[([^\]]+)\] "([A-Z]+) (\S+)[^"]*" (\d{3}|-)`)
+
+// accessLogTimeFormat is the format of the timestamps of the openapi-mock container access logs
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
 
-	logLine := strings.Split(rawLog, " ")
+// applicationLog is a line logged by the openapi-mock application itself, with log_format set to json in the mocking config
+type applicationLog struct {
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+	Error   string `json:"error"`
+}
 
-	timeStamp := strings.TrimPrefix(logLine[3], "[")
-	method := strings.TrimPrefix(logLine[5], "\"")
-	path := logLine[6]
-	statusCode := logLine[8]
+// newAccessLogEntry parses a line logged by the container. Lines that aren't access logs
+// are returned as errors so warnings and errors of the mock server are reported.
+func newAccessLogEntry(rawLog string) (mocking.AccessLogEntry, error) {
+	if match := accessLogLine.FindStringSubmatch(rawLog); match != nil {
+		timeStamp, err := time.Parse(accessLogTimeFormat, match[1])
+		if err != nil {
+			timeStamp = time.Now()
+		}
 
-	return AccessLogEntry{
-		TimeStamp:  timeStamp,
-		Method:     method,
-		Path:       path,
-		StatusCode: statusCode,
-	}, nil
+		return mocking.AccessLogEntry{
+			TimeStamp:  timeStamp,
+			Method:     match[2],
+			Path:       match[3],
+			StatusCode: match[4],
+		}, nil
+	}
+
+	// logs are multiplexed with a binary header when the container doesn't run with a TTY
+	line := strings.TrimSpace(rawLog)
+	if i := strings.IndexByte(line, '{'); i >= 0 {
+		var appLog applicationLog
+		if err := json.Unmarshal([]byte(line[i:]), &appLog); err == nil && appLog.Message != "" {
+			message := appLog.Message
+			if appLog.Error != "" {
+				message += ": " + appLog.Error
+			}
+			return mocking.AccessLogEntry{}, fmt.Errorf("%s: %s", appLog.Level, message)
+		}
+	}
+
+	return mocking.AccessLogEntry{}, fmt.Errorf("unrecognized mock server log: %s", line)
 }
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+var errResourceNotFound = errors.New("resource not found")
+
+// resource describes how a path of the spec maps onto a collection of resources.
+// Item paths are paths whose last segment is a path parameter, i.e. /todos/{id}, and they
+// belong to the collection at their parent path, /todos.
+type resource struct {
+	// collection is the path template of the collection, e.g. /todos or /todos/{todoId}/comments
+	collection string
+	// idParam is the path parameter identifying an item, empty for the collection path itself
+	idParam string
+	// idField is the property of an item holding its id
+	idField string
+}
+
+// inferResources maps the paths of the spec onto the collections they read and modify
+func inferResources(apiSpec *openapi3.T) map[string]resource {
+	resources := map[string]resource{}
+
+	for path, pathItem := range apiSpec.Paths {
+		segments := splitPath(path)
+		last := segments[len(segments)-1]
+		if !isPathParam(last) {
+			continue
+		}
+
+		collection := "/" + strings.Join(segments[:len(segments)-1], "/")
+		idParam := strings.Trim(last, "{}")
+		idField := "id"
+		if itemHasProperty(pathItem.Get, idParam) {
+			idField = idParam
+		}
+
+		resources[path] = resource{collection: collection, idParam: idParam, idField: idField}
+		if _, ok := apiSpec.Paths[collection]; ok {
+			resources[collection] = resource{collection: collection, idField: idField}
+		}
+	}
+
+	return resources
+}
+
+// itemHasProperty checks if the JSON response of an operation has the given property
+func itemHasProperty(operation *openapi3.Operation, property string) bool {
+	if operation == nil {
+		return false
+	}
+
+	_, response := selectResponse(operation)
+	if response == nil {
+		return false
+	}
+
+	for contentType, mediaType := range response.Content {
+		if isJSON(contentType) && mediaType.Schema != nil && mediaType.Schema.Value != nil {
+			if _, ok := mediaType.Schema.Value.Properties[property]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resourceStore keeps resources written through the mock server in memory so they can be read back
+type resourceStore struct {
+	mu          sync.Mutex
+	collections map[string]*collection
+}
+
+type collection struct {
+	ids    []string
+	items  map[string]map[string]interface{}
+	nextId int64
+}
+
+func newResourceStore() *resourceStore {
+	return &resourceStore{
+		collections: map[string]*collection{},
+	}
+}
+
+// reset forgets all stored resources
+func (s *resourceStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.collections = map[string]*collection{}
+}
+
+func (s *resourceStore) collection(path string) *collection {
+	c, ok := s.collections[path]
+	if !ok {
+		c = &collection{items: map[string]map[string]interface{}{}, nextId: 1}
+		s.collections[path] = c
+	}
+
+	return c
+}
+
+// collectionPath substitutes the path parameters of the request in the path template of a collection so nested
+// collections such as /todos/{todoId}/comments are kept apart for each of their parents, e.g. /todos/1/comments
+func collectionPath(template string, pathParams map[string]string) string {
+	segments := splitPath(template)
+	for i, segment := range segments {
+		if isPathParam(segment) {
+			segments[i] = pathParams[strings.Trim(segment, "{}")]
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// apply reads or modifies the stored resources according to the request.
+// The generated body is used to fill in the properties missing from created resources and
+// is returned unchanged when the request doesn't map onto a stored resource.
+func (s *resourceStore) apply(r *http.Request, res resource, pathParams map[string]string, status int, generated interface{}) (int, interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.collection(collectionPath(res.collection, pathParams))
+
+	if res.idParam == "" {
+		switch r.Method {
+		case http.MethodGet:
+			if _, ok := generated.([]interface{}); !ok {
+				return status, generated, nil
+			}
+
+			items := make([]interface{}, 0, len(c.ids))
+			for _, id := range c.ids {
+				items = append(items, c.items[id])
+			}
+			return status, items, nil
+		case http.MethodPost:
+			item, err := requestObject(r)
+			if err != nil {
+				return http.StatusBadRequest, nil, err
+			}
+
+			id, ok := item[res.idField]
+			item = mergeObjects(generated, item)
+			if !ok {
+				id = item[res.idField]
+				if !isStringId(id) {
+					id = c.nextId
+					c.nextId++
+				}
+				item[res.idField] = id
+			}
+
+			c.put(fmt.Sprint(id), item)
+			return status, item, nil
+		}
+
+		return status, generated, nil
+	}
+
+	id := pathParams[res.idParam]
+	existing, exists := c.items[id]
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			return http.StatusNotFound, nil, errResourceNotFound
+		}
+		return status, existing, nil
+	case http.MethodPut:
+		item, err := requestObject(r)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+
+		item[res.idField] = typedId(id, generated, res.idField)
+		c.put(id, item)
+		return status, item, nil
+	case http.MethodPatch:
+		if !exists {
+			return http.StatusNotFound, nil, errResourceNotFound
+		}
+
+		patch, err := requestObject(r)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+
+		item := mergeObjects(existing, patch)
+		item[res.idField] = existing[res.idField]
+		c.put(id, item)
+		return status, item, nil
+	case http.MethodDelete:
+		if !exists {
+			return http.StatusNotFound, nil, errResourceNotFound
+		}
+
+		c.delete(id)
+		return status, generated, nil
+	}
+
+	return status, generated, nil
+}
+
+func (c *collection) put(id string, item map[string]interface{}) {
+	if _, ok := c.items[id]; !ok {
+		c.ids = append(c.ids, id)
+	}
+	c.items[id] = item
+
+	// keep sequential ids ahead of the ones chosen by clients
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil && n >= c.nextId {
+		c.nextId = n + 1
+	}
+}
+
+func (c *collection) delete(id string) {
+	delete(c.items, id)
+
+	for i := range c.ids {
+		if c.ids[i] == id {
+			c.ids = append(c.ids[:i], c.ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// requestObject decodes the JSON object sent in the request body
+func requestObject(r *http.Request) (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || !isJSON(contentType) {
+		return object, nil
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&object); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("request body must be a JSON object: %w", err)
+	}
+
+	return object, nil
+}
+
+// mergeObjects overlays the properties of override over base when base is an object
+func mergeObjects(base interface{}, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	if object, ok := base.(map[string]interface{}); ok {
+		for k, v := range object {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range override {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// isStringId reports whether a generated id can be kept as is, e.g. a uuid.
+// Generated numeric ids are replaced by sequential ones so created resources are easy to address.
+func isStringId(id interface{}) bool {
+	s, ok := id.(string)
+	return ok && s != ""
+}
+
+// typedId converts an id from a path parameter to the type of id the spec uses for the resource
+func typedId(id string, generated interface{}, idField string) interface{} {
+	object, ok := generated.(map[string]interface{})
+	if !ok {
+		return id
+	}
+
+	switch object[idField].(type) {
+	case int64, float64:
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			return n
+		}
+	}
+
+	return id
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// validationError describes a part of a request that doesn't match the spec
+type validationError struct {
+	// Location points at the failing part of the request, e.g. query.limit or body.items.0.name
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// validateRequest checks the request against the parameters and request body of the operation it was routed to
+func validateRequest(r *http.Request, route *routers.Route, pathParams map[string]string) []validationError {
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError: true,
+			// authentication isn't the concern of request validation
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	if err == nil {
+		return nil
+	}
+
+	return toValidationErrors(err)
+}
+
+// toValidationErrors flattens the errors returned by openapi3filter.
+// Type switches are used instead of errors.As as multi errors match the first of any of the errors they hold.
+func toValidationErrors(err error) []validationError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var validationErrors []validationError
+		for _, err := range e {
+			validationErrors = append(validationErrors, toValidationErrors(err)...)
+		}
+		return validationErrors
+	case *openapi3filter.RequestError:
+		location := "request"
+		switch {
+		case e.Parameter != nil:
+			location = fmt.Sprintf("%s.%s", e.Parameter.In, e.Parameter.Name)
+		case e.RequestBody != nil:
+			location = "body"
+		}
+
+		if schemaErrs, ok := e.Err.(openapi3.MultiError); ok {
+			validationErrors := make([]validationError, 0, len(schemaErrs))
+			for _, err := range schemaErrs {
+				validationErrors = append(validationErrors, schemaValidationError(location, e, err))
+			}
+			return validationErrors
+		}
+
+		return []validationError{schemaValidationError(location, e, e.Err)}
+	}
+
+	return []validationError{{Location: "request", Message: err.Error()}}
+}
+
+// schemaValidationError extends the location with the path within the schema that failed, when there is one
+func schemaValidationError(location string, requestErr *openapi3filter.RequestError, err error) validationError {
+	if schemaErr, ok := err.(*openapi3.SchemaError); ok {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			location += "." + strings.Join(pointer, ".")
+		}
+		return validationError{Location: location, Message: schemaErr.Reason}
+	}
+
+	message := requestErr.Reason
+	if err != nil {
+		if message == "" {
+			message = err.Error()
+		} else {
+			message += ": " + err.Error()
+		}
+	}
+
+	return validationError{Location: location, Message: message}
+}
+
+// validationErrorsMessage summarises validation errors in a single line for the access log
+func validationErrorsMessage(validationErrors []validationError) string {
+	messages := make([]string, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		messages = append(messages, fmt.Sprintf("%s: %s", e.Location, e.Message))
+	}
+
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,115 @@
+package urlpoller
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// URLPoller fetches an OpenAPI spec served from a URL at an interval to notice when it changes.
+// Conditional requests are made with the ETag and Last-Modified of the previous response when the server sets them,
+// and the content is hashed so servers answering with the same spec again don't trigger a reload.
+type URLPoller struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	etag         string
+	lastModified string
+	hash         [sha256.Size]byte
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a poller reporting changes of the spec at the url from the version loaded,
+// so a version published after it was loaded is reported on the first poll
+func New(url string, interval time.Duration, loaded []byte) (*URLPoller, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %s", interval)
+	}
+
+	return &URLPoller{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		hash:     sha256.Sum256(loaded),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// poll fetches the spec and returns whether it changed since the previous poll
+func (p *URLPoller) poll() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return false, err
+	}
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	if p.lastModified != "" {
+		req.Header.Set("If-Modified-Since", p.lastModified)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch %s: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unable to fetch %s: %s", p.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("unable to read %s: %w", p.url, err)
+	}
+
+	p.etag = resp.Header.Get("ETag")
+	p.lastModified = resp.Header.Get("Last-Modified")
+
+	hash := sha256.Sum256(body)
+	if hash == p.hash {
+		return false, nil
+	}
+	p.hash = hash
+
+	return true, nil
+}
+
+// Watch calls fu whenever the spec changes, until the poller is closed
+func (p *URLPoller) Watch(fu func()) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := p.poll()
+			if err != nil {
+				// the spec may be unavailable for a while, keep polling
+				log.Println("error:", err)
+				continue
+			}
+			if changed {
+				fu()
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *URLPoller) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
@@ -0,0 +1,135 @@
+package urlpoller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specServer serves a spec that can be changed during the test, counting the requests answered with 304 Not Modified
+type specServer struct {
+	mu          sync.Mutex
+	spec        string
+	etag        string
+	notModified int
+}
+
+func (s *specServer) set(spec, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spec, s.etag = spec, etag
+}
+
+func (s *specServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.etag != "" {
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+	}
+
+	w.Write([]byte(s.spec))
+}
+
+func TestURLPollerPoll(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &specServer{spec: "openapi: 3.0.0", etag: `"v1"`}
+	server := httptest.NewServer(spec)
+	defer server.Close()
+
+	poller, err := New(server.URL, time.Hour, []byte("openapi: 3.0.0"))
+	require.NoError(t, err)
+
+	changed, err := poller.poll()
+	assert.NoError(err)
+	assert.False(changed)
+
+	changed, err = poller.poll()
+	assert.NoError(err)
+	assert.False(changed)
+	assert.Equal(1, spec.notModified)
+
+	spec.set("openapi: 3.0.1", `"v2"`)
+	changed, err = poller.poll()
+	assert.NoError(err)
+	assert.True(changed)
+
+	// without validators the content is compared
+	spec.set("openapi: 3.0.1", "")
+	changed, err = poller.poll()
+	assert.NoError(err)
+	assert.False(changed)
+
+	spec.set("openapi: 3.0.2", "")
+	changed, err = poller.poll()
+	assert.NoError(err)
+	assert.True(changed)
+}
+
+func TestURLPollerWatch(t *testing.T) {
+	spec := &specServer{spec: "openapi: 3.0.0"}
+	server := httptest.NewServer(spec)
+	defer server.Close()
+
+	poller, err := New(server.URL, 10*time.Millisecond, []byte("openapi: 3.0.0"))
+	require.NoError(t, err)
+
+	changes := make(chan struct{}, 10)
+	go poller.Watch(func() {
+		changes <- struct{}{}
+	})
+	defer poller.Close()
+
+	spec.set("openapi: 3.0.1", "")
+
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not reported")
+	}
+
+	select {
+	case <-changes:
+		t.Fatal("unchanged spec reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestURLPollerPollPublishedAfterLoad(t *testing.T) {
+	spec := &specServer{spec: "openapi: 3.0.1", etag: `"v2"`}
+	server := httptest.NewServer(spec)
+	defer server.Close()
+
+	poller, err := New(server.URL, time.Hour, []byte("openapi: 3.0.0"))
+	require.NoError(t, err)
+
+	changed, err := poller.poll()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestURLPollerPollUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	poller, err := New(server.URL, time.Second, nil)
+	require.NoError(t, err)
+
+	_, err = poller.poll()
+	assert.Error(t, err)
+
+	_, err = New(server.URL, 0, nil)
+	assert.Error(t, err)
+}
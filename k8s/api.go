@@ -0,0 +1,151 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// APIResource is the kusk gateway API resource generated from OpenAPI specs
+var APIResource = schema.GroupVersionResource{Group: "gateway.kusk.io", Version: "v1alpha1", Resource: "apis"}
+
+// APIFinalizer is added to API resources by the kusk gateway controller once it reconciled them
+const APIFinalizer = "gateway.kusk.io/apifinalizer"
+
+// fieldManager owns the fields of the resources kusk applies
+const fieldManager = "kusk"
+
+// apiPollInterval is how often the API resource is fetched again while waiting for the controller
+const apiPollInterval = time.Second
+
+// ApplyAPI creates or updates the API resource of the manifest with a server-side apply, taking over the fields
+// set by other managers such as kubectl. Requests rejected by the admission webhooks of kusk gateway are returned as an AdmissionError.
+func ApplyAPI(ctx context.Context, client dynamic.Interface, manifest []byte) (*unstructured.Unstructured, error) {
+	b, err := yaml.YAMLToJSON(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode API manifest: %w", err)
+	}
+
+	var api unstructured.Unstructured
+	if err := api.UnmarshalJSON(b); err != nil {
+		return nil, fmt.Errorf("unable to decode API manifest: %w", err)
+	}
+
+	force := true
+	applied, err := client.Resource(APIResource).Namespace(api.GetNamespace()).Patch(ctx, api.GetName(), types.ApplyPatchType, b, metav1.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		return nil, apiError(api.GetNamespace(), api.GetName(), err)
+	}
+
+	return applied, nil
+}
+
+// GetAPI returns the API resource deployed in the cluster
+func GetAPI(ctx context.Context, client dynamic.Interface, namespace, name string) (*unstructured.Unstructured, error) {
+	api, err := client.Resource(APIResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, apiError(namespace, name, err)
+	}
+
+	return api, nil
+}
+
+// PickedUp tells whether the kusk gateway controller has picked up the API resource, which it reports by adding its finalizer.
+// The controller doesn't report the generation it reconciled, so once an API is picked up its updates can't be told apart.
+func PickedUp(api *unstructured.Unstructured) bool {
+	for _, finalizer := range api.GetFinalizers() {
+		if finalizer == APIFinalizer {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WaitForAPI waits until the kusk gateway controller has picked up the API resource. It only covers the creation
+// of APIs: an API already picked up is returned right away, whether or not the controller reconciled its last update.
+func WaitForAPI(ctx context.Context, client dynamic.Interface, namespace, name string) error {
+	err := wait.PollImmediateUntilWithContext(ctx, apiPollInterval, func(ctx context.Context) (bool, error) {
+		api, err := GetAPI(ctx, client, namespace, name)
+		if err != nil {
+			return false, err
+		}
+
+		if api.GetDeletionTimestamp() != nil {
+			return false, fmt.Errorf("API %s/%s is being deleted", namespace, name)
+		}
+
+		return PickedUp(api), nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("API %s/%s wasn't picked up by the kusk gateway controller in time, check that kusk gateway is running with kubectl get pods -n kusk-system", namespace, name)
+	}
+
+	return err
+}
+
+// AdmissionError is returned when the API server or the admission webhooks of kusk gateway reject an API resource
+type AdmissionError struct {
+	Namespace, Name string
+	Message         string
+	// Causes lists the fields rejected, when the API server reported them
+	Causes []string
+}
+
+func (e *AdmissionError) Error() string {
+	message := fmt.Sprintf("API %s/%s was rejected: %s", e.Namespace, e.Name, e.Message)
+	for _, cause := range e.Causes {
+		message += "\n  - " + cause
+	}
+
+	return message
+}
+
+// apiError explains the errors returned by the API server for API resources
+func apiError(namespace, name string, err error) error {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return err
+	}
+
+	// a missing API is reported with its details, a missing API resource type without
+	details := status.Status().Details
+	switch {
+	case apierrors.IsNotFound(err) && details != nil && details.Kind == APIResource.Resource && details.Name == name:
+		return fmt.Errorf("API %s/%s not found", namespace, name)
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("the %s resource isn't known to the cluster, install kusk gateway with kusk install first: %w", APIResource.GroupResource(), err)
+	case apierrors.IsBadRequest(err), apierrors.IsInvalid(err), apierrors.IsForbidden(err), apierrors.IsConflict(err):
+		admissionErr := &AdmissionError{
+			Namespace: namespace,
+			Name:      name,
+			Message:   strings.TrimSpace(status.Status().Message),
+		}
+		if details != nil {
+			for _, cause := range details.Causes {
+				if cause.Field != "" {
+					admissionErr.Causes = append(admissionErr.Causes, cause.Field+": "+cause.Message)
+					continue
+				}
+				admissionErr.Causes = append(admissionErr.Causes, cause.Message)
+			}
+		}
+		return admissionErr
+	}
+
+	return err
+}
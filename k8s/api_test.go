@@ -0,0 +1,111 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testManifest = `
+---
+apiVersion: gateway.kusk.io/v1alpha1
+kind: API
+metadata:
+  name: todos
+  namespace: default
+spec:
+  fleet:
+    name: default
+    namespace: kusk-system
+  spec: |
+    openapi: 3.0.0
+`
+
+func newTestAPI(finalizers ...string) *unstructured.Unstructured {
+	api := &unstructured.Unstructured{}
+	api.SetAPIVersion("gateway.kusk.io/v1alpha1")
+	api.SetKind("API")
+	api.SetNamespace("default")
+	api.SetName("todos")
+	api.SetFinalizers(finalizers)
+
+	return api
+}
+
+func newFakeClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{APIResource: "APIList"}, objects...)
+}
+
+func TestApplyAPI(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	client := newFakeClient()
+	var patch k8stesting.PatchAction
+	client.PrependReactor("patch", "apis", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch = action.(k8stesting.PatchAction)
+		return true, newTestAPI(), nil
+	})
+
+	api, err := ApplyAPI(context.Background(), client, []byte(testManifest))
+	require.NoError(t, err)
+	assert.Equal("todos", api.GetName())
+	assert.Equal("default", patch.GetNamespace())
+	assert.Equal("todos", patch.GetName())
+	assert.JSONEq(`{
+		"apiVersion": "gateway.kusk.io/v1alpha1",
+		"kind": "API",
+		"metadata": {"name": "todos", "namespace": "default"},
+		"spec": {"fleet": {"name": "default", "namespace": "kusk-system"}, "spec": "openapi: 3.0.0\n"}
+	}`, string(patch.GetPatch()))
+}
+
+func TestApplyAPIRejected(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	client := newFakeClient()
+	client.PrependReactor("patch", "apis", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    400,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: `admission webhook "vapi.kb.io" denied the request: spec: x-kusk should be a valid set of options: upstream: service: port: cannot be blank.`,
+		}}
+	})
+
+	_, err := ApplyAPI(context.Background(), client, []byte(testManifest))
+	var admissionErr *AdmissionError
+	require.ErrorAs(t, err, &admissionErr)
+	assert.Equal(`API default/todos was rejected: admission webhook "vapi.kb.io" denied the request: spec: x-kusk should be a valid set of options: upstream: service: port: cannot be blank.`, err.Error())
+}
+
+func TestWaitForAPI(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the controller hasn't picked up the API yet
+	err := WaitForAPI(ctx, newFakeClient(newTestAPI()), "default", "todos")
+	assert.ErrorContains(err, "wasn't picked up by the kusk gateway controller in time")
+
+	assert.NoError(WaitForAPI(context.Background(), newFakeClient(newTestAPI(APIFinalizer)), "default", "todos"))
+
+	err = WaitForAPI(context.Background(), newFakeClient(), "default", "todos")
+	assert.EqualError(err, "API default/todos not found")
+
+	assert.False(PickedUp(newTestAPI()))
+	assert.True(PickedUp(newTestAPI("other", APIFinalizer)))
+}
@@ -0,0 +1,44 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ServiceResource is the resource of the services upstreams route to
+var ServiceResource = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+
+// GetServicePorts returns the ports exposed by the service
+func GetServicePorts(ctx context.Context, client dynamic.Interface, namespace, name string) ([]int64, error) {
+	service, err := client.Resource(ServiceResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("service %s/%s not found", namespace, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	servicePorts, _, err := unstructured.NestedSlice(service.Object, "spec", "ports")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.ports of service %s/%s: %w", namespace, name, err)
+	}
+
+	ports := make([]int64, 0, len(servicePorts))
+	for _, servicePort := range servicePorts {
+		port, ok := servicePort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if number, ok, _ := unstructured.NestedInt64(port, "port"); ok {
+			ports = append(ports, number)
+		}
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestGetServicePorts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	service := &unstructured.Unstructured{}
+	service.SetAPIVersion("v1")
+	service.SetKind("Service")
+	service.SetNamespace("default")
+	service.SetName("pets")
+	require.NoError(t, unstructured.SetNestedSlice(service.Object, []interface{}{
+		map[string]interface{}{"name": "http", "port": int64(80)},
+		map[string]interface{}{"name": "grpc", "port": int64(9090)},
+	}, "spec", "ports"))
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{ServiceResource: "ServiceList"}, service)
+
+	ports, err := GetServicePorts(context.Background(), client, "default", "pets")
+	require.NoError(t, err)
+	assert.Equal([]int64{80, 9090}, ports)
+
+	_, err = GetServicePorts(context.Background(), client, "default", "owners")
+	assert.EqualError(err, "service default/owners not found")
+}
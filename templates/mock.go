@@ -0,0 +1,103 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2022 Kubeshop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+
+*/
+package templates
+
+type MockDockerfileTemplateArgs struct {
+	KuskVersion string
+	// BuilderImage compiles kusk and BaseImage runs it, both pinned by digest
+	// so the same context always builds from the same images
+	BuilderImage string
+	BaseImage    string
+	// Entrypoint is the kusk mock command line as a JSON array
+	Entrypoint string
+}
+
+var MockDockerfileTemplate = `# Generated by kusk mock build, serves the mocked APIs the same way kusk mock does
+FROM {{ .BuilderImage }} AS kusk
+# the dependencies are the ones kusk is released with, verified against the Go checksum database
+RUN CGO_ENABLED=0 go install -trimpath github.com/kubeshop/kusk@{{ .KuskVersion }}
+
+FROM {{ .BaseImage }}
+COPY --from=kusk /go/bin/kusk /usr/local/bin/kusk
+ENV HOME=/mock
+COPY openapi-mock.yaml /mock/.kusk/openapi-mock.yaml
+COPY specs/ /mock/specs/
+EXPOSE 8080
+ENTRYPOINT {{ .Entrypoint }}
+`
+
+type MockKubernetesTemplateArgs struct {
+	Name      string
+	Namespace string
+	Image     string
+}
+
+var MockKubernetesTemplate = `# Generated by kusk mock build, build and push the image from the Dockerfile next to this file first
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: {{ .Name }}
+    app.kubernetes.io/managed-by: kusk
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: {{ .Name }}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{ .Name }}
+        app.kubernetes.io/managed-by: kusk
+    spec:
+      containers:
+        - name: mock
+          image: {{ .Image }}
+          ports:
+            - name: http
+              containerPort: 8080
+          readinessProbe:
+            tcpSocket:
+              port: http
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: {{ .Name }}
+    app.kubernetes.io/managed-by: kusk
+spec:
+  selector:
+    app.kubernetes.io/name: {{ .Name }}
+  ports:
+    - name: http
+      port: 80
+      targetPort: http
+`